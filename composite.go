@@ -0,0 +1,70 @@
+package ratelimit
+
+import "context"
+
+// allLimiter admits the request only if every limiter admits it.
+type allLimiter []Limiter
+
+// All combines limiters, the request is admitted only if all of them admit
+// it. Limiters are visited in order, admissions of earlier limiters are
+// rolled back with Op Ignore when a later limiter rejects the request:
+// concurrency limiters like bbr release the request without recording it,
+// and rate limiters like token bucket give the permits back. The DoneInfo
+// is forwarded to every limiter.
+//
+// Limiters which wait for their turn, such as leaky bucket, block before
+// the later limiters are asked, so a rejection by the later one comes only
+// after the wait. Put them last to avoid waiting for nothing.
+func All(limiters ...Limiter) Limiter {
+	return allLimiter(limiters)
+}
+
+func (l allLimiter) Allow(ctx context.Context, opts ...AllowOption) (func(info DoneInfo), error) {
+	dones := make([]func(info DoneInfo), 0, len(l))
+	for _, limiter := range l {
+		done, err := limiter.Allow(ctx, opts...)
+		if err != nil {
+			for i := len(dones) - 1; i >= 0; i-- {
+				dones[i](DoneInfo{Op: Ignore})
+			}
+			return nil, err
+		}
+		dones = append(dones, done)
+	}
+
+	return func(info DoneInfo) {
+		for i := len(dones) - 1; i >= 0; i-- {
+			dones[i](info)
+		}
+	}, nil
+}
+
+// anyLimiter admits the request if any limiter admits it.
+type anyLimiter []Limiter
+
+// Any combines limiters, the request is admitted if any of them admits it.
+// Limiters are visited in order and the first admission wins, the DoneInfo
+// is forwarded to the admitted limiter only. If all limiters reject the
+// request, the error of the first limiter is returned.
+func Any(limiters ...Limiter) Limiter {
+	return anyLimiter(limiters)
+}
+
+func (l anyLimiter) Allow(ctx context.Context, opts ...AllowOption) (func(info DoneInfo), error) {
+	var first error
+	for _, limiter := range l {
+		done, err := limiter.Allow(ctx, opts...)
+		if err == nil {
+			return done, nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+
+	if first == nil {
+		first = ErrLimitExceed
+	}
+
+	return nil, first
+}
@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAll(t *testing.T) {
+	a, b := &mockLimiter{quota: 2}, &mockLimiter{quota: 1}
+	l := All(a, b)
+
+	done, err := l.Allow(context.Background())
+	assert.NoError(t, err)
+	info := DoneInfo{Err: errors.New("failed"), Op: Success}
+	done(info)
+	assert.Equal(t, []DoneInfo{info}, a.infos)
+	assert.Equal(t, []DoneInfo{info}, b.infos)
+
+	// b rejects, so admission of a is rolled back.
+	_, err = l.Allow(context.Background())
+	assert.True(t, errors.Is(err, ErrLimitExceed))
+	assert.Equal(t, []DoneInfo{info, {Op: Ignore}}, a.infos)
+	assert.Equal(t, 1, a.quota)
+	assert.Len(t, b.infos, 1)
+}
+
+func TestAny(t *testing.T) {
+	a, b := &mockLimiter{quota: 1}, &mockLimiter{quota: 1}
+	l := Any(a, b)
+
+	for _, m := range []*mockLimiter{a, b} {
+		done, err := l.Allow(context.Background())
+		assert.NoError(t, err)
+		done(DoneInfo{Op: Success})
+		assert.Equal(t, []DoneInfo{{Op: Success}}, m.infos)
+	}
+
+	_, err := l.Allow(context.Background())
+	assert.True(t, errors.Is(err, ErrLimitExceed))
+
+	_, err = Any().Allow(context.Background())
+	assert.True(t, errors.Is(err, ErrLimitExceed))
+}
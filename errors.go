@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reason indicates why a request is limited.
+type Reason string
+
+const (
+	// ReasonCPUOverload CPU usage is over the threshold.
+	ReasonCPUOverload Reason = "cpu_overload"
+	// ReasonInflightOverload requests in flight are more than the system could handle.
+	ReasonInflightOverload Reason = "inflight_overload"
+	// ReasonTokensExhausted there are not enough tokens.
+	ReasonTokensExhausted Reason = "tokens_exhausted"
+	// ReasonQueueFull the waiting queue is full.
+	ReasonQueueFull Reason = "queue_full"
+	// ReasonDeadline the request could not be allowed before its deadline.
+	ReasonDeadline Reason = "deadline"
+	// ReasonThrottled the backend rejects too many requests recently, so the
+	// client throttles requests locally.
+	ReasonThrottled Reason = "throttled"
+	// ReasonCircuitOpen the circuit breaker is open, or the half-open breaker
+	// has no more probes.
+	ReasonCircuitOpen Reason = "circuit_open"
+)
+
+// LimitError is raised when a request is limited, it carries the details
+// of the rejection and matches ErrLimitExceed with errors.Is.
+type LimitError struct {
+	// Limiter name of the limiter who rejects the request.
+	Limiter string
+	// Reason why the request is limited.
+	Reason Reason
+	// Observed values of metrics those the limiter made decision with.
+	Observed map[string]float64
+	// RetryAfter suggests how long the caller should wait before retrying,
+	// zero means unknown.
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	b := strings.Builder{}
+	b.WriteString(ErrLimitExceed.Error())
+	if e.Limiter != "" {
+		b.WriteString(" by ")
+		b.WriteString(e.Limiter)
+	}
+	b.WriteString(": ")
+	b.WriteString(string(e.Reason))
+
+	if len(e.Observed) != 0 {
+		keys := make([]string, 0, len(e.Observed))
+		for k := range e.Observed {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, fmt.Sprintf("%s=%g", k, e.Observed[k]))
+		}
+		b.WriteString(" (")
+		b.WriteString(strings.Join(pairs, ", "))
+		b.WriteString(")")
+	}
+
+	if e.RetryAfter > 0 {
+		b.WriteString(", retry after ")
+		b.WriteString(e.RetryAfter.String())
+	}
+
+	return b.String()
+}
+
+// Is makes LimitError matches ErrLimitExceed.
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceed
+}
+
+// RetryAfterSeconds returns RetryAfter in whole seconds rounded up,
+// it's useful to fill the HTTP Retry-After header.
+func (e *LimitError) RetryAfterSeconds() int64 {
+	return int64(math.Ceil(e.RetryAfter.Seconds()))
+}
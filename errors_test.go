@@ -0,0 +1,28 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitError(t *testing.T) {
+	var err error = &LimitError{
+		Limiter: "bbr",
+		Reason:  ReasonCPUOverload,
+		Observed: map[string]float64{
+			"inflight": 10,
+			"cpu":      900,
+		},
+		RetryAfter: 1500 * time.Millisecond,
+	}
+
+	assert.True(t, errors.Is(err, ErrLimitExceed))
+	assert.Equal(t, "request is limited by bbr: cpu_overload (cpu=900, inflight=10), retry after 1.5s", err.Error())
+
+	var le *LimitError
+	assert.True(t, errors.As(err, &le))
+	assert.Equal(t, int64(2), le.RetryAfterSeconds())
+}
@@ -6,8 +6,8 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/yeqown/ratelimit"
 	"github.com/yeqown/ratelimit/impl/bbr"
+	"github.com/yeqown/ratelimit/middleware/httplimit"
 )
 
 func init() {
@@ -36,21 +36,13 @@ func main() {
 // withBBR middleware
 func withBBR(f http.HandlerFunc) http.HandlerFunc {
 	l := bbr.New(nil)
+	h := httplimit.New(&httplimit.Config{Limiter: l})(f)
 
 	return func(w http.ResponseWriter, req *http.Request) {
 		defer func() {
 			fmt.Printf("%+v\n", l.(*bbr.BBR).Stat())
 		}()
 
-		done, err := l.Allow(req.Context())
-		if err != nil {
-			w.WriteHeader(http.StatusTooManyRequests)
-			_, _ = fmt.Fprintf(w, "Error: %v", err)
-			return
-		}
-
-		defer done(ratelimit.DoneInfo{Op: ratelimit.Success})
-
-		f(w, req)
+		h.ServeHTTP(w, req)
 	}
 }
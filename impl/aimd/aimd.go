@@ -0,0 +1,117 @@
+package aimd
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	limit "github.com/yeqown/ratelimit"
+	"github.com/yeqown/ratelimit/internal/concurrency"
+	"github.com/yeqown/ratelimit/window"
+)
+
+// AIMD implements adaptive concurrency limiter with additive increase and
+// multiplicative decrease, like TCP congestion control. The concurrency
+// limit is increased by Increase once a request succeeds while the limit
+// is well used, and it's multiplied by BackoffRatio once a request is
+// dropped, failed or slower than RTThreshold.
+//
+// It protects services where CPU is not the bottleneck, such as services
+// waiting on database or downstream services.
+type AIMD struct {
+	conf *Config
+	// gate admits requests while requests in flight are under limit.
+	gate *concurrency.Gate
+
+	// mu for limit safety while concurrent visiting.
+	mu sync.Mutex
+	// limit the concurrency limit.
+	limit float64
+	// rt RT of requests in window, the unit is time.Microsecond.
+	rt *window.RollingCounter
+}
+
+// statForDebug contains the metrics' snapshot of aimd.
+type statForDebug struct {
+	Limit    int64
+	InFlight int64
+	AvgRT    time.Duration
+}
+
+var _ limit.Limiter = (*AIMD)(nil)
+
+// New create an aimd limiter.
+func New(conf *Config) limit.Limiter {
+	conf = compatibleConfig(conf)
+
+	l := &AIMD{
+		conf:  conf,
+		gate:  concurrency.NewGate(conf.Name),
+		limit: float64(conf.InitialLimit),
+		rt: window.NewRollingCounter(conf.WinBucket,
+			conf.Window/time.Duration(conf.WinBucket)),
+	}
+
+	return l
+}
+
+// Limit returns the current concurrency limit.
+func (l *AIMD) Limit() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int64(l.limit)
+}
+
+// avgRT returns the average RT in window.
+func (l *AIMD) avgRT() time.Duration {
+	return time.Duration(l.rt.Avg()) * time.Microsecond
+}
+
+// update adjusts the limit by the outcome of request, inflight is the count
+// of requests in flight when the request was allowed.
+func (l *AIMD) update(info limit.DoneInfo, rt time.Duration, inflight int64) {
+	overloaded := info.Op == limit.Drop || info.Err != nil || rt > l.conf.RTThreshold
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if overloaded {
+		l.limit = math.Max(float64(l.conf.MinLimit), math.Floor(l.limit*l.conf.BackoffRatio))
+		return
+	}
+
+	// do not increase the limit while it's not well used, or the limit
+	// would grow unbounded under low traffic.
+	if float64(inflight)*2 >= l.limit {
+		l.limit = math.Min(float64(l.conf.MaxLimit), l.limit+l.conf.Increase)
+	}
+}
+
+// Stat tasks a snapshot of the aimd limiter.
+func (l *AIMD) Stat() statForDebug {
+	return statForDebug{
+		Limit:    l.Limit(),
+		InFlight: l.gate.InFlight(),
+		AvgRT:    l.avgRT(),
+	}
+}
+
+// Allow checks requests in flight are under the limit or not.
+func (l *AIMD) Allow(ctx context.Context, opts ...limit.AllowOption) (func(info limit.DoneInfo), error) {
+	allowOpts := limit.DefaultAllowOpts()
+	for _, opt := range opts {
+		opt.Apply(&allowOpts)
+	}
+
+	if allowOpts.Expired() {
+		return nil, context.DeadlineExceeded
+	}
+
+	return l.gate.Allow(l.Limit(), allowOpts.Cost, l.avgRT,
+		func(info limit.DoneInfo, rt time.Duration, inflight int64) {
+			l.rt.Add(int64(rt / time.Microsecond))
+			l.update(info, rt, inflight)
+		})
+}
@@ -0,0 +1,67 @@
+package aimd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yeqown/ratelimit"
+)
+
+func TestNew(t *testing.T) {
+	l := New(&Config{InitialLimit: 2000}).(*AIMD)
+
+	assert.Equal(t, int64(1000), l.Limit())
+	assert.Equal(t, 0.9, l.conf.BackoffRatio)
+}
+
+func TestAIMD_increase(t *testing.T) {
+	l := New(&Config{InitialLimit: 2, MaxLimit: 3}).(*AIMD)
+
+	done1, err := l.Allow(context.Background())
+	assert.NoError(t, err)
+	done2, err := l.Allow(context.Background())
+	assert.NoError(t, err)
+
+	// additive increase, but never over MaxLimit.
+	done1(ratelimit.DoneInfo{Op: ratelimit.Success})
+	assert.Equal(t, int64(3), l.Limit())
+	done2(ratelimit.DoneInfo{Op: ratelimit.Success})
+	assert.Equal(t, int64(3), l.Limit())
+	assert.Equal(t, int64(0), l.Stat().InFlight)
+
+	// the limit is not increased while it's not well used.
+	l = New(&Config{InitialLimit: 10}).(*AIMD)
+	done, _ := l.Allow(context.Background())
+	done(ratelimit.DoneInfo{Op: ratelimit.Success})
+	assert.Equal(t, int64(10), l.Limit())
+}
+
+func TestAIMD_backoff(t *testing.T) {
+	l := New(&Config{InitialLimit: 100, MinLimit: 70, RTThreshold: 5 * time.Millisecond}).(*AIMD)
+
+	infos := []ratelimit.DoneInfo{
+		{Op: ratelimit.Drop},
+		{Err: errors.New("failed"), Op: ratelimit.Success},
+		{Op: ratelimit.Ignore},
+	}
+	for _, info := range infos {
+		done, err := l.Allow(context.Background())
+		assert.NoError(t, err)
+		done(info)
+	}
+	assert.Equal(t, int64(81), l.Limit())
+
+	// slower than RTThreshold, but never under MinLimit.
+	for i := 0; i < 2; i++ {
+		done, err := l.Allow(context.Background())
+		assert.NoError(t, err)
+		time.Sleep(6 * time.Millisecond)
+		done(ratelimit.DoneInfo{Op: ratelimit.Success})
+	}
+	assert.Equal(t, int64(70), l.Limit())
+	assert.True(t, l.Stat().AvgRT > 0)
+}
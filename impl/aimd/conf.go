@@ -0,0 +1,88 @@
+package aimd
+
+import "time"
+
+var (
+	defaultConf = &Config{
+		Name:         "aimd",
+		Window:       time.Second * 10,
+		WinBucket:    100,
+		InitialLimit: 20,
+		MinLimit:     1,
+		MaxLimit:     1000,
+		Increase:     1,
+		BackoffRatio: 0.9,
+		RTThreshold:  time.Second * 5,
+	}
+)
+
+// Config contains configs of aimd limiter.
+type Config struct {
+	// Name of the limiter, it's reported in limit.LimitError.
+	Name string
+	// Window time.Duration of window contains, RT of requests in window are
+	// tracked.
+	Window time.Duration
+	// WinBucket indicates how many bucket the window holds.
+	WinBucket uint32
+	// InitialLimit indicates the concurrency limit at the beginning.
+	InitialLimit int64
+	// MinLimit indicates the minimum concurrency limit.
+	MinLimit int64
+	// MaxLimit indicates the maximum concurrency limit.
+	MaxLimit int64
+	// Increase indicates how much the limit is increased by once a request
+	// succeeds while the limit is well used.
+	Increase float64
+	// BackoffRatio indicates the limit is multiplied by it once a request
+	// is dropped, failed or slower than RTThreshold, it's in (0, 1).
+	BackoffRatio float64
+	// RTThreshold indicates requests slower than it are overloaded.
+	RTThreshold time.Duration
+}
+
+func compatibleConfig(conf *Config) *Config {
+	if conf == nil {
+		c := *defaultConf
+		conf = &c
+	}
+
+	if conf.Name == "" {
+		conf.Name = defaultConf.Name
+	}
+	if conf.Window <= 0 {
+		conf.Window = defaultConf.Window
+	}
+	if conf.WinBucket <= 0 {
+		conf.WinBucket = defaultConf.WinBucket
+	}
+	if conf.MinLimit <= 0 {
+		conf.MinLimit = defaultConf.MinLimit
+	}
+	if conf.MaxLimit <= 0 {
+		conf.MaxLimit = defaultConf.MaxLimit
+	}
+	if conf.MaxLimit < conf.MinLimit {
+		conf.MaxLimit = conf.MinLimit
+	}
+	if conf.InitialLimit <= 0 {
+		conf.InitialLimit = defaultConf.InitialLimit
+	}
+	if conf.InitialLimit < conf.MinLimit {
+		conf.InitialLimit = conf.MinLimit
+	}
+	if conf.InitialLimit > conf.MaxLimit {
+		conf.InitialLimit = conf.MaxLimit
+	}
+	if conf.Increase <= 0 {
+		conf.Increase = defaultConf.Increase
+	}
+	if conf.BackoffRatio <= 0 || conf.BackoffRatio >= 1 {
+		conf.BackoffRatio = defaultConf.BackoffRatio
+	}
+	if conf.RTThreshold <= 0 {
+		conf.RTThreshold = defaultConf.RTThreshold
+	}
+
+	return conf
+}
@@ -19,12 +19,21 @@ var (
 // https://github.com/alibaba/Sentinel/wiki/%E7%B3%BB%E7%BB%9F%E8%87%AA%E9%80%82%E5%BA%94%E9%99%90%E6%B5%81
 type BBR struct {
 	conf *Config
-	cpu  func() int64
 
-	// complete contains count of completed request count in one bucket duration.
+	// triggers indicate whether the system is overloaded, the CPU
+	// trigger is used by default.
+	triggers []Trigger
+
+	// sampler provides cpu load, it's closed in Close only if it's
+	// created by the limiter itself.
+	sampler      *CPUSampler
+	ownedSampler bool
+
+	// complete contains count of completed request count in one bucket duration,
+	// each point is the cost of a completed request.
 	complete *rw.RollingWindow
 
-	// rt contains all completed requests round-trip time (millisecond).
+	// rt contains all completed requests round-trip time (conf.RTPrecision).
 	rt *rw.RollingWindow
 
 	// inflight requests in dealing per second.
@@ -36,13 +45,15 @@ type BBR struct {
 	bps int64
 
 	// prevDrop the previous dropped request's time gap from _InitTime
-	// if this is not 0, means the system need to limit traffic
+	// if this is not 0 and in conf.DropCooldown, means the system need to
+	// limit traffic
 	prevDrop atomic.Value
 
 	// rawMaxComplete means BDP (Bandwidth Delayed Product)
 	rawMaxComplete int64
 
-	// rawMinRT means minRTT of all request in past duration of window.
+	// rawMinRT means minRTT of all request in past duration of window,
+	// in unit of conf.RTPrecision.
 	rawMinRT int64
 }
 
@@ -53,20 +64,47 @@ func New(conf *Config) limit.Limiter {
 
 	l := &BBR{
 		conf:     conf,
-		cpu:      cpugetter,
+		triggers: conf.Triggers,
+		sampler:  conf.CPUSampler,
 		complete: rw.NewRollingWindow(conf.WinBucket, d),
-		rt:       rw.NewRollingWindow(conf.WinBucket, d),
+		rt:       newRTWindow(conf, d),
 		inflight: 0,
 		bps:      int64(conf.WinBucket) / int64(conf.Window/time.Second),
 	}
 
-	// continuously get cpu load. init cpu = l.conf.CPUThreshold,
-	// to start with low request.
-	go cpuproc(l.conf.CPUThreshold)
+	if len(l.triggers) == 0 {
+		if l.sampler == nil {
+			// continuously get cpu load. init cpu = l.conf.CPUThreshold,
+			// to start with low request.
+			l.sampler = NewCPUSampler(l.conf.CPUThreshold)
+			l.ownedSampler = true
+		}
+		l.triggers = []Trigger{{Signal: l.sampler, Threshold: float64(l.conf.CPUThreshold)}}
+	}
 
 	return l
 }
 
+// newRTWindow create the window of RT, buckets keep histogram only if
+// percentile statistic is used to estimate minRTT.
+func newRTWindow(conf *Config, d time.Duration) *rw.RollingWindow {
+	if conf.MinRTTStatistic == StatAvg {
+		return rw.NewRollingWindow(conf.WinBucket, d)
+	}
+
+	return rw.NewRollingWindow(conf.WinBucket, d, rw.WithHistogram())
+}
+
+// Close releases the resources of limiter, the CPUSampler created by
+// limiter itself would be closed.
+func (l *BBR) Close() error {
+	if l.ownedSampler {
+		return l.sampler.Close()
+	}
+
+	return nil
+}
+
 func (l *BBR) maxComplete() int64 {
 	c := atomic.LoadInt64(&l.rawMaxComplete)
 	if c > 0 && l.complete.TimeSpan() < 1 {
@@ -77,7 +115,7 @@ func (l *BBR) maxComplete() int64 {
 	// Stat from window.buckets
 	r := 1.0
 	l.complete.Iterate(func(b *rw.Bucket) {
-		r = math.Max(r, float64(b.Count()))
+		r = math.Max(r, float64(b.Sum()))
 	})
 
 	c = int64(r)
@@ -89,10 +127,26 @@ func (l *BBR) maxComplete() int64 {
 	return c
 }
 
+// bucketRT returns the RT statistic of bucket b selected by
+// conf.MinRTTStatistic.
+func (l *BBR) bucketRT(b *rw.Bucket) float64 {
+	switch l.conf.MinRTTStatistic {
+	case StatP50:
+		return b.Percentile(50)
+	case StatP90:
+		return b.Percentile(90)
+	case StatP99:
+		return b.Percentile(99)
+	default:
+		return b.Avg()
+	}
+}
+
 // minRTT get minimum round-trip time from rt (RollingWindow).
 //
 // the minimum RTT is an metric of one bucket in all window buckets,
-// it only contains one bucket duration data.
+// it only contains one bucket duration data, and the metric is selected
+// by conf.MinRTTStatistic.
 func (l *BBR) minRTT() int64 {
 	rawMinRTT := atomic.LoadInt64(&l.rawMinRT)
 	if rawMinRTT > 0 && l.rt.TimeSpan() < 1 {
@@ -104,8 +158,7 @@ func (l *BBR) minRTT() int64 {
 		if b.Count() == 0 {
 			return
 		}
-		// FIXME: avg is not so good. maybe p99 or p90
-		r = math.Min(r, b.Avg())
+		r = math.Min(r, l.bucketRT(b))
 	})
 
 	rawMinRTT = int64(math.Ceil(r))
@@ -117,119 +170,205 @@ func (l *BBR) minRTT() int64 {
 	return rawMinRTT
 }
 
+// shouldDrop means is there need to limit request.
+//
+// While the system is overloaded (CPU is over CPUThreshold by default),
+// requests over maxFlight would be dropped. And in DropCooldown since the
+// previous dropped request, requests over maxFlight are still dropped even
+// if signals dip, so that the limiter would not flap on every sample.
+//
+// requests in lower priority would be dropped earlier, since they could
+// only use less headroom of maxFlight.
+//
 // https://github.com/alibaba/sentinel-golang/blob/master/core/system/slot.go
-func (l *BBR) shouldDropV2() bool {
-	cpu := l.cpu()
-	if cpu > l.conf.CPUThreshold {
-		if !l.checkSimple() {
-			return true
+func (l *BBR) shouldDrop(p limit.Priority) *limit.LimitError {
+	tripped, overloaded := l.overloaded()
+	if !overloaded {
+		// no trigger is tripped, then get prevDrop
+		prevDrop, _ := l.prevDrop.Load().(time.Duration)
+		if prevDrop == 0 {
+			// cpu is ok and no previous dropped request, just complete
+			return nil
+		}
+
+		if l.conf.DropCooldown > 0 && time.Since(_InitTime)-prevDrop <= l.conf.DropCooldown {
+			// previous dropped request happened in cooldown,
+			// this means to keep limiting request.
+			if !l.checkSimple(p) {
+				return l.overload(limit.ReasonInflightOverload, p)
+			}
+			return nil
+		}
+
+		// no need to limit and drop request.
+		// clear previous dropped request gap
+		l.prevDrop.Store(time.Duration(0))
+		return nil
+	}
+
+	// signal is exceed threshold, such as cpu
+	if !l.checkSimple(p) {
+		l.prevDrop.Store(time.Since(_InitTime))
+		return l.overload(limit.Reason(tripped.Signal.Name()+"_overload"), p)
+	}
+
+	return nil
+}
+
+// overloaded checks triggers by conf.CombineMode, returns the (first)
+// tripped trigger if the system is overloaded.
+func (l *BBR) overloaded() (*Trigger, bool) {
+	var tripped *Trigger
+	for i := range l.triggers {
+		t := &l.triggers[i]
+		over := t.Signal.Value() > t.Threshold
+
+		switch l.conf.CombineMode {
+		case CombineAll:
+			if !over {
+				return nil, false
+			}
+			if tripped == nil {
+				tripped = t
+			}
+		default:
+			if over {
+				return t, true
+			}
+		}
+	}
+
+	return tripped, tripped != nil
+}
+
+// overload creates the error about system overload with the metrics'
+// snapshot, and suggests the caller to retry after requests in flight
+// over maxFlight could be completed.
+func (l *BBR) overload(reason limit.Reason, p limit.Priority) *limit.LimitError {
+	inflight := atomic.LoadInt64(&l.inflight)
+	maxFlight := l.maxFlight() * l.headroom(p)
+
+	retry := l.conf.Window / time.Duration(l.conf.WinBucket)
+	if rate := float64(l.maxComplete() * l.bps); rate > 0 {
+		excess := time.Duration((float64(inflight) - maxFlight) / rate * float64(time.Second))
+		if excess > retry {
+			retry = excess
 		}
 	}
 
-	// if cpu is under of CPUThreshold, no strategy for this case.
-	// TODO(@yeqown): do something or no need to do.
+	observed := map[string]float64{
+		"inflight":   float64(inflight),
+		"max_flight": maxFlight,
+		"priority":   float64(p),
+	}
+	for _, t := range l.triggers {
+		observed[t.Signal.Name()] = t.Signal.Value()
+		observed[t.Signal.Name()+"_threshold"] = t.Threshold
+	}
 
-	return false
+	return &limit.LimitError{
+		Limiter:    l.conf.Name,
+		Reason:     reason,
+		Observed:   observed,
+		RetryAfter: retry,
+	}
 }
 
 // maxFlight = math.Floor((MaxPass * MinRTT * WindowSize)/1000 + 0.5)
 // estimate how many request could be accepted by server in bucket duration.
+// MinRTT is converted into seconds from conf.RTPrecision.
 func (l *BBR) maxFlight() float64 {
-	return float64(l.maxComplete()*l.minRTT()) / 1000.0
+	minRTT := time.Duration(l.minRTT()) * l.conf.RTPrecision
+	return float64(l.maxComplete()) * minRTT.Seconds()
+}
+
+// headroom returns the fraction of maxFlight could be used by requests
+// in priority p.
+func (l *BBR) headroom(p limit.Priority) float64 {
+	if h, ok := l.conf.PriorityHeadroom[p]; ok {
+		return h
+	}
+
+	return 1.0
 }
 
-func (l *BBR) checkSimple() bool {
+func (l *BBR) checkSimple(p limit.Priority) bool {
 	concurrency := atomic.LoadInt64(&l.inflight)
-	minRt := l.minRTT()
-	maxComplete := l.maxComplete()
-	if concurrency > 1 && float64(concurrency) > float64(maxComplete*minRt)/1000.0 {
-		//if concurrency > 1 && float64(concurrency) > l.maxFlight() {
+	if concurrency > 1 && float64(concurrency) > l.maxFlight()*l.headroom(p) {
 		return false
 	}
 
 	return true
 }
 
-//// shouldDrop means is there need to limit request.
-//func (l *BBR) shouldDrop() bool {
-//	if l.cpu() < l.conf.CPUThreshold {
-//		// cpu is less than conf.CPUThreshold, then get prevDrop
-//		prevDrop, _ := l.prevDrop.Load().(time.Duration)
-//		if prevDrop == 0 {
-//			// cpu is ok and no previous dropped request, just complete
-//			return false
-//		}
-//
-//		if time.Since(_InitTime)-prevDrop <= time.Second {
-//			// if previous dropped request happened before and over than 1s.
-//			// this means to limit request
-//			inFlight := atomic.LoadInt64(&l.inflight)
-//			return inFlight > 1 && float64(inFlight) > l.maxFlight()
-//		}
-//
-//		// no need to limit and drop request.
-//		// clear previous dropped request gap
-//		l.prevDrop.Store(time.Duration(0))
-//		return false
-//	}
-//
-//	// cpu is exceed limit
-//	inFlight := atomic.LoadInt64(&l.inflight)
-//	drop := inFlight > 1 && float64(inFlight) > l.maxFlight()
-//	if drop {
-//		prevDrop, _ := l.prevDrop.Load().(time.Duration)
-//		if prevDrop == 0 {
-//			// update the prevDrop at when only the current request should drop and prevDrop is not exists
-//			l.prevDrop.Store(time.Since(_InitTime))
-//		}
-//	}
-//
-//	return drop
-//}
-
 // statForDebug contains the metrics' snapshot of bbr.
 type statForDebug struct {
-	CPU         int64 // CPU usage
-	InFlight    int64 // count of requests in flight
-	MaxInFlight int64 // the maximum count of requests could be handled by system
-	MinRTT      int64 // the minimum RT
-	MaxPass     int64 // the maximum ?
+	CPU         int64              // CPU usage, it's 0 if CPU is not sampled
+	Signals     map[string]float64 // values of signals in triggers
+	InFlight    int64              // count of requests in flight
+	MaxInFlight int64              // the maximum count of requests could be handled by system
+	MinRTT      time.Duration      // the minimum RT
+	MaxPass     int64              // the maximum ?
 }
 
 // statForDebug tasks a snapshot of the bbr limiter.
 func (l *BBR) Stat() statForDebug {
+	cpu := int64(0)
+	if l.sampler != nil {
+		cpu = l.sampler.Load()
+	}
+	signals := make(map[string]float64, len(l.triggers))
+	for _, t := range l.triggers {
+		signals[t.Signal.Name()] = t.Signal.Value()
+	}
+
 	return statForDebug{
-		CPU:         l.cpu(),
+		CPU:         cpu,
+		Signals:     signals,
 		InFlight:    atomic.LoadInt64(&l.inflight),
-		MinRTT:      l.minRTT(),
+		MinRTT:      time.Duration(l.minRTT()) * l.conf.RTPrecision,
 		MaxPass:     l.maxComplete(),
 		MaxInFlight: int64(l.maxFlight()),
 	}
 }
 
-// Allow checks all inbound traffic.
-// Once overload is detected, it raises limit.ErrLimitExceed error.
+// Allow checks all inbound traffic, the request with limit.WithCost(n)
+// is counted as n requests in flight and n completed requests, and the
+// request with limit.WithPriority(p) is shed according to p's headroom.
+// Once overload is detected, it raises *limit.LimitError which matches
+// limit.ErrLimitExceed.
 func (l *BBR) Allow(ctx context.Context, opts ...limit.AllowOption) (func(info limit.DoneInfo), error) {
 	allowOpts := limit.DefaultAllowOpts()
 	for _, opt := range opts {
 		opt.Apply(&allowOpts)
 	}
 
-	if l.shouldDropV2() {
-		return nil, limit.ErrLimitExceed
+	if allowOpts.Expired() {
+		return nil, context.DeadlineExceeded
+	}
+
+	if err := l.shouldDrop(allowOpts.Priority); err != nil {
+		return nil, err
 	}
 
-	atomic.AddInt64(&l.inflight, 1)
+	cost := allowOpts.Cost
+	atomic.AddInt64(&l.inflight, cost)
 	start := time.Now()
 
 	return func(do limit.DoneInfo) {
-		cost := time.Since(start) / time.Millisecond
-		l.rt.Add(int64(cost))
-		atomic.AddInt64(&l.inflight, -1)
+		atomic.AddInt64(&l.inflight, -cost)
+		// ignored requests, such as canceled by client or rolled back,
+		// tell nothing about the capacity.
+		if do.Op == limit.Ignore {
+			return
+		}
+
+		rt := time.Since(start) / l.conf.RTPrecision
+		l.rt.Add(int64(rt))
 
 		switch do.Op {
 		case limit.Success:
-			l.complete.Add(1)
+			l.complete.Add(cost)
 			return
 		default:
 			return
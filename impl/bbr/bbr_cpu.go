@@ -3,6 +3,7 @@ package bbr
 import (
 	"fmt"
 	"os"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -14,39 +15,103 @@ const (
 )
 
 var (
-	// cpu is the load value of present CPU
-	cpu int64
-
 	// decay is a parameter to calculate cpu load, it's value in [0.0, 1.0].
 	decay = 0.95
 )
 
-func cpugetter() int64 {
-	return atomic.LoadInt64(&cpu)
+// CPUSampler always get "Moving Average" of current cpu usage in background
+// until it's closed. It could be shared by reference among limiters.
+type CPUSampler struct {
+	// cpu is the load value of present CPU
+	cpu int64
+
+	stats cpustat.CPU
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	// exited is closed after the sampling goroutine exits.
+	exited chan struct{}
+}
+
+// NewCPUSampler create a CPUSampler and start sampling, init cpu = _init,
+// to start with low request. Close must be called once it's not used.
+//
+// If cpu usage could not be read, it only prints error to os.Stderr,
+// and the load value keeps _init.
+func NewCPUSampler(_init int64) *CPUSampler {
+	s := &CPUSampler{
+		cpu:    _init,
+		closed: make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+
+	var err error
+	if s.stats, err = cpustat.New(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "rate.limit.NewCPUSampler() err(%+v)", err)
+		close(s.exited)
+		return s
+	}
+
+	go s.cpuproc()
+
+	return s
+}
+
+// Load returns the load value of present CPU.
+func (s *CPUSampler) Load() int64 {
+	return atomic.LoadInt64(&s.cpu)
+}
+
+// Close stops sampling, and waits for the sampling goroutine exits.
+// It's safe to call Close multiple times.
+func (s *CPUSampler) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+	<-s.exited
+
+	return nil
 }
 
 // cpuproc always get "Moving Average" of current cpu usage.
 // cpu = cpuᵗ⁻¹ * decay + cpuᵗ * (1 - decay)
-func cpuproc(_init int64) {
-	if _init > 0 {
-		atomic.StoreInt64(&cpu, _init)
+func (s *CPUSampler) cpuproc() {
+	defer close(s.exited)
+
+	// EMA algorithm: https://blog.csdn.net/m0_38106113/article/details/81542863
+	for {
+		// restart sampling if it's recovered from panic.
+		if s.sample() {
+			return
+		}
 	}
+}
 
+// sample reads cpu usage until closed, returns false if it's
+// recovered from panic and should be restarted.
+func (s *CPUSampler) sample() (exited bool) {
 	ticker := time.NewTicker(_QueryCPUdelay)
 	defer func() {
 		ticker.Stop()
 		if err := recover(); err != nil {
 			_, _ = fmt.Fprintf(os.Stderr, "rate.limit.cpuproc() err(%+v)", err)
-			go cpuproc(_init)
+			exited = false
 		}
 	}()
 
-	// EMA algorithm: https://blog.csdn.net/m0_38106113/article/details/81542863
-	for range ticker.C {
-		stat := &cpustat.Stat{}
-		cpustat.ReadStat(stat)
-		pre := atomic.LoadInt64(&cpu)
-		cur := int64(float64(pre)*decay + float64(stat.Usage)*(1.0-decay))
-		atomic.StoreInt64(&cpu, cur)
+	for {
+		select {
+		case <-s.closed:
+			return true
+		case <-ticker.C:
+		}
+
+		u, err := s.stats.Usage()
+		if err != nil {
+			continue
+		}
+		pre := atomic.LoadInt64(&s.cpu)
+		cur := int64(float64(pre)*decay + float64(u)*(1.0-decay))
+		atomic.StoreInt64(&s.cpu, cur)
 	}
 }
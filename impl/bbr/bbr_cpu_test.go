@@ -1,21 +1,46 @@
 package bbr
 
 import (
+	"runtime"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
-func Test_cpugetter(t *testing.T) {
-	count := 10
-	ticker := time.NewTicker(1 * time.Second)
-	go cpuproc(500)
+func Test_CPUSampler(t *testing.T) {
+	s := NewCPUSampler(500)
+	defer s.Close()
+
+	assert.Equal(t, int64(500), s.Load())
+	for i := 0; i < 5; i++ {
+		time.Sleep(200 * time.Millisecond)
+		t.Log(s.Load())
+	}
+}
 
-	for range ticker.C {
-		t.Log(cpugetter())
-		count--
+func Test_CPUSampler_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
 
-		if count < 0 {
-			break
-		}
+	for i := 0; i < 10; i++ {
+		l := New(nil).(*BBR)
+		assert.NoError(t, l.Close())
+		// close twice is ok.
+		assert.NoError(t, l.Close())
 	}
+
+	// shared sampler is not closed by limiters.
+	s := NewCPUSampler(500)
+	l1 := New(&Config{CPUSampler: s}).(*BBR)
+	l2 := New(&Config{CPUSampler: s}).(*BBR)
+	assert.NoError(t, l1.Close())
+	assert.NoError(t, l2.Close())
+	select {
+	case <-s.exited:
+		t.Fatal("shared sampler should not be closed")
+	default:
+	}
+	assert.NoError(t, s.Close())
+
+	assert.Equal(t, before, runtime.NumGoroutine())
 }
@@ -2,20 +2,40 @@ package bbr
 
 import (
 	"context"
+	"errors"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/yeqown/ratelimit"
+	rw "github.com/yeqown/ratelimit/internal/rolling-window"
 )
 
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
+// mockSignal is an OverloadSignal with name and value function.
+type mockSignal struct {
+	name  string
+	value func() float64
+}
+
+func (s mockSignal) Name() string   { return s.name }
+func (s mockSignal) Value() float64 { return s.value() }
+
+// mockCPU replaces the CPU trigger of l with cpu function.
+func mockCPU(l *BBR, cpu func() int64) {
+	l.triggers = []Trigger{{
+		Signal:    mockSignal{name: "cpu", value: func() float64 { return float64(cpu()) }},
+		Threshold: float64(l.conf.CPUThreshold),
+	}}
+}
+
 func TestBBR_Allow(t *testing.T) {
 	l := New(nil)
 	wg := sync.WaitGroup{}
@@ -27,7 +47,7 @@ func TestBBR_Allow(t *testing.T) {
 			defer wg.Done()
 
 			doneNotify, err := l.Allow(context.Background())
-			if err == ratelimit.ErrLimitExceed {
+			if errors.Is(err, ratelimit.ErrLimitExceed) {
 				t.Log("LimitErrLimitExceed triggered")
 				return
 			}
@@ -67,3 +87,249 @@ func TestNew(t *testing.T) {
 	assert.Equal(t, 10*time.Second, bbr.conf.Window)
 
 }
+
+func TestNew_nilConfig(t *testing.T) {
+	limiters := make([]*BBR, 4)
+	wg := sync.WaitGroup{}
+	wg.Add(len(limiters))
+	for i := range limiters {
+		go func(i int) {
+			defer wg.Done()
+			limiters[i] = New(nil).(*BBR)
+		}(i)
+	}
+	wg.Wait()
+
+	// limiters never share the config, neither the default.
+	limiters[0].conf.PriorityHeadroom[ratelimit.PriorityLow] = 0.1
+	assert.Equal(t, 0.5, limiters[1].conf.PriorityHeadroom[ratelimit.PriorityLow])
+	assert.Equal(t, 0.5, defaultConf.PriorityHeadroom[ratelimit.PriorityLow])
+	assert.Equal(t, int64(0), defaultConf.CPUThreshold)
+	for _, l := range limiters {
+		assert.True(t, l.conf != defaultConf)
+		assert.NoError(t, l.Close())
+	}
+}
+
+func TestBBR_Allow_options(t *testing.T) {
+	l := New(nil).(*BBR)
+
+	_, err := l.Allow(context.Background(), ratelimit.WithDeadline(time.Now().Add(-time.Second)))
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	done, err := l.Allow(context.Background(), ratelimit.WithCost(3))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), l.Stat().InFlight)
+	done(ratelimit.DoneInfo{Op: ratelimit.Success})
+	assert.Equal(t, int64(0), l.Stat().InFlight)
+
+	completed := int64(0)
+	l.complete.Iterate(func(b *rw.Bucket) {
+		completed += b.Sum()
+	})
+	assert.Equal(t, int64(3), completed)
+}
+
+func TestBBR_Allow_ignore(t *testing.T) {
+	l := New(nil).(*BBR)
+
+	done, err := l.Allow(context.Background())
+	assert.NoError(t, err)
+	done(ratelimit.DoneInfo{Op: ratelimit.Ignore})
+	assert.Equal(t, int64(0), l.Stat().InFlight)
+
+	// neither RT nor completion of ignored request is recorded.
+	count := int64(0)
+	l.rt.Iterate(func(b *rw.Bucket) {
+		count += int64(b.Count())
+	})
+	l.complete.Iterate(func(b *rw.Bucket) {
+		count += int64(b.Count())
+	})
+	assert.Equal(t, int64(0), count)
+}
+
+func TestBBR_Allow_overload(t *testing.T) {
+	l := New(&Config{CPUThreshold: 800}).(*BBR)
+	mockCPU(l, func() int64 { return 900 })
+
+	// minRTT and maxComplete are 1 at least, so maxFlight is 0.000001,
+	// but requests are always allowed while at most one is in flight.
+	_, err := l.Allow(context.Background())
+	assert.NoError(t, err)
+	_, err = l.Allow(context.Background())
+	assert.NoError(t, err)
+
+	_, err = l.Allow(context.Background())
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+
+	le := err.(*ratelimit.LimitError)
+	assert.Equal(t, "bbr", le.Limiter)
+	assert.Equal(t, ratelimit.ReasonCPUOverload, le.Reason)
+	assert.Equal(t, float64(900), le.Observed["cpu"])
+	assert.Equal(t, float64(2), le.Observed["inflight"])
+	assert.True(t, le.RetryAfter >= 100*time.Millisecond)
+}
+
+func TestBBR_Allow_priority(t *testing.T) {
+	l := New(&Config{CPUThreshold: 800}).(*BBR)
+	mockCPU(l, func() int64 { return 900 })
+
+	// maxFlight = 50 * 100ms = 5
+	l.complete.Add(50)
+	l.rt.Add(int64(100 * time.Millisecond / time.Microsecond))
+	assert.InDelta(t, float64(5), l.maxFlight(), 1e-9)
+
+	atomic.StoreInt64(&l.inflight, 4)
+	_, err := l.Allow(context.Background(), ratelimit.WithPriority(ratelimit.PriorityLow))
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	_, err = l.Allow(context.Background())
+	assert.NoError(t, err)
+
+	atomic.StoreInt64(&l.inflight, 7)
+	_, err = l.Allow(context.Background())
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	_, err = l.Allow(context.Background(), ratelimit.WithPriority(ratelimit.PriorityHigh))
+	assert.NoError(t, err)
+
+	atomic.StoreInt64(&l.inflight, 9)
+	_, err = l.Allow(context.Background(), ratelimit.WithPriority(ratelimit.PriorityHigh))
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	_, err = l.Allow(context.Background(), ratelimit.WithPriority(ratelimit.PriorityCritical))
+	assert.NoError(t, err)
+}
+
+func TestConfig_PriorityHeadroom(t *testing.T) {
+	conf := compatibleConfig(&Config{
+		PriorityHeadroom: map[ratelimit.Priority]float64{
+			ratelimit.PriorityLow: 0.2,
+		},
+	})
+
+	assert.Equal(t, 0.2, conf.PriorityHeadroom[ratelimit.PriorityLow])
+	assert.Equal(t, 1.0, conf.PriorityHeadroom[ratelimit.PriorityNormal])
+	assert.Equal(t, 0.5, defaultConf.PriorityHeadroom[ratelimit.PriorityLow])
+}
+
+// flappingDrops counts dropped requests while CPU flaps around the
+// threshold on every sample and requests in flight are over maxFlight.
+func flappingDrops(l *BBR) int {
+	var cpu int64
+	mockCPU(l, func() int64 { return atomic.LoadInt64(&cpu) })
+	atomic.StoreInt64(&l.inflight, 10)
+
+	dropped := 0
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			atomic.StoreInt64(&cpu, 900)
+		} else {
+			atomic.StoreInt64(&cpu, 700)
+		}
+
+		if _, err := l.Allow(context.Background()); err != nil {
+			dropped++
+			continue
+		}
+		atomic.AddInt64(&l.inflight, -1)
+	}
+
+	return dropped
+}
+
+func TestBBR_DropCooldown(t *testing.T) {
+	// without cooldown, the limiter flaps with CPU.
+	l := New(&Config{CPUThreshold: 800, DropCooldown: -1}).(*BBR)
+	assert.Equal(t, 50, flappingDrops(l))
+
+	// with cooldown, the limiter keeps limiting while CPU dips.
+	l = New(&Config{CPUThreshold: 800, DropCooldown: 50 * time.Millisecond}).(*BBR)
+	assert.Equal(t, 100, flappingDrops(l))
+
+	mockCPU(l, func() int64 { return 700 })
+	_, err := l.Allow(context.Background())
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	assert.Equal(t, ratelimit.ReasonInflightOverload, err.(*ratelimit.LimitError).Reason)
+
+	// cooldown is over, CPU is ok.
+	time.Sleep(60 * time.Millisecond)
+	_, err = l.Allow(context.Background())
+	assert.NoError(t, err)
+	prevDrop, _ := l.prevDrop.Load().(time.Duration)
+	assert.Equal(t, time.Duration(0), prevDrop)
+}
+
+func TestBBR_minRTT_statistic(t *testing.T) {
+	rts := []int64{10, 10, 10, 10, 10, 10, 10, 10, 100, 1000}
+	expects := map[Statistic]int64{
+		StatAvg: 118,
+		StatP50: 10,
+		StatP90: 100,
+		StatP99: 1000,
+	}
+
+	for stat, expect := range expects {
+		l := New(&Config{MinRTTStatistic: stat}).(*BBR)
+		for _, rt := range rts {
+			l.rt.Add(rt)
+		}
+		// percentiles are estimated by histogram with relative error 1/8.
+		assert.InDelta(t, expect, l.minRTT(), float64(expect)/8, "statistic=%d", stat)
+	}
+}
+
+func TestBBR_RTPrecision(t *testing.T) {
+	l := New(nil).(*BBR)
+
+	done, err := l.Allow(context.Background())
+	assert.NoError(t, err)
+	for start := time.Now(); time.Since(start) < 200*time.Microsecond; {
+		// spin rather than sleep, since timer is not so precise.
+	}
+	done(ratelimit.DoneInfo{Op: ratelimit.Success})
+
+	// sub-millisecond RT is recorded.
+	minRTT := l.Stat().MinRTT
+	assert.True(t, minRTT >= 200*time.Microsecond && minRTT < time.Millisecond, "minRTT=%v", minRTT)
+
+	l = New(&Config{RTPrecision: time.Millisecond}).(*BBR)
+	l.rt.Add(100)
+	l.complete.Add(50)
+	assert.Equal(t, 100*time.Millisecond, l.Stat().MinRTT)
+	assert.InDelta(t, float64(5), l.maxFlight(), 1e-9)
+}
+
+func TestBBR_Triggers(t *testing.T) {
+	heap, goroutines := 0.5, 100.0
+	triggers := []Trigger{
+		{Signal: mockSignal{name: "heap", value: func() float64 { return heap }}, Threshold: 0.8},
+		{Signal: mockSignal{name: "goroutines", value: func() float64 { return goroutines }}, Threshold: 1000},
+	}
+
+	l := New(&Config{Triggers: triggers}).(*BBR)
+	assert.Nil(t, l.sampler)
+	atomic.StoreInt64(&l.inflight, 10)
+
+	_, err := l.Allow(context.Background())
+	assert.NoError(t, err)
+
+	// any trigger is tripped.
+	heap = 0.9
+	_, err = l.Allow(context.Background())
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	le := err.(*ratelimit.LimitError)
+	assert.Equal(t, ratelimit.Reason("heap_overload"), le.Reason)
+	assert.Equal(t, 0.9, le.Observed["heap"])
+	assert.Equal(t, 0.8, le.Observed["heap_threshold"])
+	assert.Equal(t, float64(100), le.Observed["goroutines"])
+
+	// all triggers must be tripped.
+	l = New(&Config{Triggers: triggers, CombineMode: CombineAll, DropCooldown: -1}).(*BBR)
+	atomic.StoreInt64(&l.inflight, 10)
+	_, err = l.Allow(context.Background())
+	assert.NoError(t, err)
+
+	goroutines = 2000
+	_, err = l.Allow(context.Background())
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	assert.Equal(t, map[string]float64{"heap": 0.9, "goroutines": 2000}, l.Stat().Signals)
+}
@@ -3,18 +3,46 @@ package bbr
 import (
 	"runtime"
 	"time"
+
+	limit "github.com/yeqown/ratelimit"
 )
 
 var (
 	defaultConf = &Config{
+		Name:         "bbr",
 		Window:       time.Second * 10,
 		WinBucket:    100,
 		CPUThreshold: 0,
+		DropCooldown: time.Second,
+		RTPrecision:  time.Microsecond,
+		PriorityHeadroom: map[limit.Priority]float64{
+			limit.PriorityLow:      0.5,
+			limit.PriorityNormal:   1.0,
+			limit.PriorityHigh:     1.5,
+			limit.PriorityCritical: 2.0,
+		},
 	}
 )
 
+// Statistic indicates which statistic of bucket's RT is used to
+// estimate minRTT.
+type Statistic int
+
+const (
+	// StatAvg average RT of bucket.
+	StatAvg Statistic = iota
+	// StatP50 median RT of bucket.
+	StatP50
+	// StatP90 90th percentile RT of bucket.
+	StatP90
+	// StatP99 99th percentile RT of bucket.
+	StatP99
+)
+
 // Config contains configs of bbr limiter.
 type Config struct {
+	// Name of the limiter, it's reported in limit.LimitError.
+	Name string
 	// Window time.Duration of window contains.
 	Window time.Duration
 	// WinBucket indicates how many bucket the window holds.
@@ -22,23 +50,73 @@ type Config struct {
 	// CPUThreshold indicates the threshold of the CPU limit.
 	// if it's not set, default is CORE * 2.5
 	CPUThreshold int64
+	// Triggers indicate whether the system is overloaded by signals beyond
+	// CPU, such as heap usage or GC pause. If it's empty, only the CPU
+	// trigger with CPUThreshold is used.
+	Triggers []Trigger
+	// CombineMode indicates how to combine Triggers, default is CombineAny.
+	CombineMode CombineMode
+	// DropCooldown indicates how long the limiter keeps limiting after the
+	// previous dropped request even if CPU is under CPUThreshold, it damps
+	// the limiter flapping on every CPU sample. Default is 1s, and negative
+	// value disables it.
+	DropCooldown time.Duration
+	// RTPrecision indicates the unit of RT recorded in window, default is
+	// time.Microsecond, so that requests faster than 1ms are not recorded
+	// as 0. minRTT is clamped to 1 unit at least.
+	RTPrecision time.Duration
+	// MinRTTStatistic indicates which statistic of each bucket is used to
+	// estimate minRTT, default is StatAvg. percentiles reflect realistic
+	// service latency better than average, they're estimated by histogram
+	// with relative error less than 1/8.
+	MinRTTStatistic Statistic
+	// CPUSampler shares the sampler among limiters, limiters would not close
+	// it. If it's nil, each limiter creates its own sampler and closes it
+	// in Close. It's only used if Triggers is empty.
+	CPUSampler *CPUSampler
+	// PriorityHeadroom indicates the fraction of maxFlight could be used by
+	// requests in each priority while overloaded. Requests in lower priority
+	// with less headroom would be shed first, missing priorities are filled
+	// by default: low=0.5, normal=1.0, high=1.5, critical=2.0.
+	PriorityHeadroom map[limit.Priority]float64
 }
 
 func compatibleConfig(conf *Config) *Config {
 	if conf == nil {
-		conf = defaultConf
+		c := *defaultConf
+		conf = &c
 	}
 
+	if conf.Name == "" {
+		conf.Name = defaultConf.Name
+	}
 	if conf.Window == 0 {
 		conf.Window = defaultConf.Window
 	}
 	if conf.WinBucket == 0 {
 		conf.WinBucket = defaultConf.WinBucket
 	}
+	if conf.DropCooldown == 0 {
+		conf.DropCooldown = defaultConf.DropCooldown
+	}
+	if conf.RTPrecision <= 0 {
+		conf.RTPrecision = defaultConf.RTPrecision
+	}
 	if conf.CPUThreshold == 0 {
 		conf.CPUThreshold = int64(float32(cores()) * 2.5 * 100)
 	}
 
+	headroom := make(map[limit.Priority]float64, len(defaultConf.PriorityHeadroom))
+	for p, h := range defaultConf.PriorityHeadroom {
+		headroom[p] = h
+	}
+	for p, h := range conf.PriorityHeadroom {
+		if h > 0 {
+			headroom[p] = h
+		}
+	}
+	conf.PriorityHeadroom = headroom
+
 	return conf
 }
 
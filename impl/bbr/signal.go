@@ -0,0 +1,225 @@
+package bbr
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	cpustat "github.com/yeqown/ratelimit/internal/cpu"
+	"github.com/yeqown/ratelimit/internal/pressure"
+)
+
+const (
+	// _SignalCacheDelay how long the value of expensive signals is cached.
+	_SignalCacheDelay = 100 * time.Millisecond
+)
+
+// OverloadSignal indicates how busy the system is, the larger the busier.
+type OverloadSignal interface {
+	// Name of the signal, it's reported in limit.LimitError.
+	Name() string
+	// Value returns present value of the signal.
+	Value() float64
+}
+
+// Trigger combines an OverloadSignal with its threshold, the system is
+// overloaded by the signal once its value is over Threshold.
+type Trigger struct {
+	Signal    OverloadSignal
+	Threshold float64
+}
+
+// CombineMode indicates how to combine several triggers.
+type CombineMode int
+
+const (
+	// CombineAny the system is overloaded if any trigger is tripped.
+	CombineAny CombineMode = iota
+	// CombineAll the system is overloaded only if all triggers are tripped.
+	CombineAll
+)
+
+var (
+	_ OverloadSignal = (*CPUSampler)(nil)
+	_ OverloadSignal = (*cachedSignal)(nil)
+	_ OverloadSignal = goroutineSignal{}
+)
+
+// Name of CPUSampler signal.
+func (s *CPUSampler) Name() string {
+	return "cpu"
+}
+
+// Value returns the load value of present CPU (permille).
+func (s *CPUSampler) Value() float64 {
+	return float64(s.Load())
+}
+
+// cachedSignal caches value of fn in _SignalCacheDelay, to avoid reading
+// expensive stats on every request.
+type cachedSignal struct {
+	name string
+	fn   func() float64
+
+	// last the time of value last updated, in unix nanoseconds.
+	last int64
+	// value bits of float64 value.
+	value uint64
+}
+
+func newCachedSignal(name string, fn func() float64) *cachedSignal {
+	return &cachedSignal{
+		name: name,
+		fn:   fn,
+	}
+}
+
+func (s *cachedSignal) Name() string {
+	return s.name
+}
+
+func (s *cachedSignal) Value() float64 {
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&s.last)
+	// only one caller could win the chance to update value.
+	if now-last >= int64(_SignalCacheDelay) && atomic.CompareAndSwapInt64(&s.last, last, now) {
+		atomic.StoreUint64(&s.value, math.Float64bits(s.fn()))
+	}
+
+	return math.Float64frombits(atomic.LoadUint64(&s.value))
+}
+
+// NewHeapSignal create a signal of Go heap usage, it's the ratio of
+// in-use heap bytes to limit, such as 0.8 means 80% of limit is used.
+// An error is returned if limit is 0.
+func NewHeapSignal(limit uint64) (OverloadSignal, error) {
+	if limit == 0 {
+		return nil, errors.New("heap limit must be positive")
+	}
+
+	return newCachedSignal("heap", func() float64 {
+		ms := runtime.MemStats{}
+		runtime.ReadMemStats(&ms)
+		return float64(ms.HeapInuse) / float64(limit)
+	}), nil
+}
+
+// NewGCPauseSignal create a signal of GC pause fraction, it's the fraction
+// of wall time spent in GC stop-the-world pauses since previous sampling.
+func NewGCPauseSignal() OverloadSignal {
+	// pauses before the signal created are not counted.
+	ms := runtime.MemStats{}
+	runtime.ReadMemStats(&ms)
+	var (
+		mu        sync.Mutex
+		prevPause = ms.PauseTotalNs
+		prevTime  = time.Now()
+	)
+
+	return newCachedSignal("gc_pause", func() float64 {
+		ms := runtime.MemStats{}
+		runtime.ReadMemStats(&ms)
+
+		mu.Lock()
+		defer mu.Unlock()
+		now := time.Now()
+		elapsed := now.Sub(prevTime)
+		pause := ms.PauseTotalNs - prevPause
+		prevTime, prevPause = now, ms.PauseTotalNs
+		if elapsed <= 0 {
+			return 0
+		}
+
+		return float64(pause) / float64(elapsed)
+	})
+}
+
+// goroutineSignal is the count of goroutines.
+type goroutineSignal struct{}
+
+// NewGoroutineSignal create a signal of the count of goroutines.
+func NewGoroutineSignal() OverloadSignal {
+	return goroutineSignal{}
+}
+
+func (goroutineSignal) Name() string {
+	return "goroutines"
+}
+
+func (goroutineSignal) Value() float64 {
+	return float64(runtime.NumGoroutine())
+}
+
+// NewCgroupMemorySignal create a signal of cgroup memory usage, it's the
+// ratio of cgroup memory usage to its limit. An error is returned if
+// cgroup could not be read or there is no memory limit.
+func NewCgroupMemorySignal() (OverloadSignal, error) {
+	_, limit, err := cpustat.ReadMemory()
+	if err != nil {
+		return nil, errors.Wrap(err, "read cgroup memory failed")
+	}
+	if limit == 0 {
+		return nil, errors.New("no cgroup memory limit")
+	}
+
+	return newCachedSignal("cgroup_memory", func() float64 {
+		usage, limit, err := cpustat.ReadMemory()
+		if err != nil || limit == 0 {
+			return 0
+		}
+		return float64(usage) / float64(limit)
+	}), nil
+}
+
+// PressureResource the resource which tasks are stalled on.
+type PressureResource string
+
+const (
+	// PressureCPU tasks are stalled on cpu.
+	PressureCPU PressureResource = "cpu"
+	// PressureMemory tasks are stalled on memory.
+	PressureMemory PressureResource = "memory"
+	// PressureIO tasks are stalled on io.
+	PressureIO PressureResource = "io"
+)
+
+// NewPressureSignal create a signal of Linux PSI (pressure stall
+// information), it's the avg10 percentage of time that some (or all if
+// full is true) tasks are stalled on res. The pressure of current
+// process's cgroup v2 is preferred, otherwise system-wide pressure in
+// /proc/pressure is used. An error is returned if PSI is not available.
+//
+// https://www.kernel.org/doc/html/latest/accounting/psi.html
+func NewPressureSignal(res PressureResource, full bool) (OverloadSignal, error) {
+	read := func() (pressure.Stat, error) {
+		return pressure.ReadCgroup(pressure.Resource(res))
+	}
+	if _, err := read(); err != nil {
+		read = func() (pressure.Stat, error) {
+			return pressure.Read(pressure.Resource(res))
+		}
+		if _, err = read(); err != nil {
+			return nil, errors.Wrap(err, "read pressure failed")
+		}
+	}
+
+	name := string(res) + "_pressure_some"
+	if full {
+		name = string(res) + "_pressure_full"
+	}
+
+	return newCachedSignal(name, func() float64 {
+		stat, err := read()
+		if err != nil {
+			return 0
+		}
+		if full {
+			return stat.Full.Avg10
+		}
+		return stat.Some.Avg10
+	}), nil
+}
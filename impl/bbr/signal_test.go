@@ -0,0 +1,60 @@
+package bbr
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedSignal(t *testing.T) {
+	calls := 0
+	s := newCachedSignal("mock", func() float64 {
+		calls++
+		return float64(calls)
+	})
+
+	assert.Equal(t, "mock", s.Name())
+	assert.Equal(t, float64(1), s.Value())
+	assert.Equal(t, float64(1), s.Value())
+
+	time.Sleep(_SignalCacheDelay)
+	assert.Equal(t, float64(2), s.Value())
+}
+
+func TestSignals(t *testing.T) {
+	_, err := NewHeapSignal(0)
+	assert.Error(t, err)
+	heap, err := NewHeapSignal(1 << 40)
+	assert.NoError(t, err)
+	assert.Equal(t, "heap", heap.Name())
+	assert.True(t, heap.Value() > 0 && heap.Value() < 1)
+
+	// pauses before the signal created are not counted.
+	for i := 0; i < 100; i++ {
+		runtime.GC()
+	}
+	gc := NewGCPauseSignal()
+	assert.Equal(t, "gc_pause", gc.Name())
+	v := gc.Value()
+	assert.True(t, v >= 0 && v <= 1, "gc_pause=%f", v)
+
+	g := NewGoroutineSignal()
+	assert.Equal(t, "goroutines", g.Name())
+	assert.True(t, g.Value() >= 1)
+
+	if s, err := NewPressureSignal(PressureCPU, false); err == nil {
+		assert.Equal(t, "cpu_pressure_some", s.Name())
+		assert.True(t, s.Value() >= 0 && s.Value() <= 100)
+	} else {
+		t.Logf("pressure is not available: %v", err)
+	}
+
+	if s, err := NewCgroupMemorySignal(); err == nil {
+		assert.Equal(t, "cgroup_memory", s.Name())
+		assert.True(t, s.Value() > 0)
+	} else {
+		t.Logf("cgroup memory is not available: %v", err)
+	}
+}
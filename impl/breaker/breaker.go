@@ -0,0 +1,288 @@
+package breaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	limit "github.com/yeqown/ratelimit"
+	"github.com/yeqown/ratelimit/window"
+)
+
+// State of circuit breaker.
+type State int
+
+const (
+	// StateClosed all calls are allowed, and outcomes are tracked in window.
+	StateClosed State = iota
+	// StateOpen all calls are rejected until OpenTimeout passes.
+	StateOpen
+	// StateHalfOpen HalfOpenProbes calls are allowed to probe whether the
+	// downstream has recovered.
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// Breaker implements circuit breaker over the limit.Limiter contract, the
+// outcome of call is reported by DoneInfo. The breaker is tripped to open
+// once the ratio of failed or slow calls in window is too high, and it
+// turns to half-open after OpenTimeout to probe the downstream.
+type Breaker struct {
+	conf *Config
+
+	// mu for states and windows safety while concurrent visiting.
+	mu    sync.Mutex
+	state State
+	// generation increases on every state change, so that calls allowed in
+	// previous state are not counted.
+	generation uint64
+	// openedAt the time of breaker opened.
+	openedAt time.Time
+	// probes count of probes allowed in half-open, and succeeded count of
+	// probes succeeded.
+	probes    int64
+	succeeded int64
+
+	requests *window.RollingCounter
+	failures *window.RollingCounter
+	slows    *window.RollingCounter
+
+	// errorRatio and slowRatio when the breaker was opened.
+	errorRatio float64
+	slowRatio  float64
+}
+
+// statForDebug contains the metrics' snapshot of breaker.
+type statForDebug struct {
+	State      State
+	Requests   int64
+	Failures   int64
+	SlowCalls  int64
+	ErrorRatio float64
+	SlowRatio  float64
+}
+
+var _ limit.Limiter = (*Breaker)(nil)
+
+// New create a circuit breaker, it's closed at the beginning.
+func New(conf *Config) limit.Limiter {
+	conf = compatibleConfig(conf)
+
+	b := &Breaker{
+		conf:  conf,
+		state: StateClosed,
+	}
+	b.resetWindows()
+
+	return b
+}
+
+// resetWindows drops calls tracked, it must be called with b.mu held.
+func (b *Breaker) resetWindows() {
+	bucketDuration := b.conf.Window / time.Duration(b.conf.WinBucket)
+	b.requests = window.NewRollingCounter(b.conf.WinBucket, bucketDuration)
+	b.failures = window.NewRollingCounter(b.conf.WinBucket, bucketDuration)
+	b.slows = window.NewRollingCounter(b.conf.WinBucket, bucketDuration)
+}
+
+// transit changes the state to, it must be called with b.mu held. The
+// returned function notifies OnStateChange, and it must be called after
+// b.mu is released.
+func (b *Breaker) transit(to State, now time.Time) func() {
+	from := b.state
+	b.state = to
+	b.generation++
+
+	switch to {
+	case StateClosed:
+		b.resetWindows()
+	case StateOpen:
+		b.openedAt = now
+	case StateHalfOpen:
+		b.probes, b.succeeded = 0, 0
+	}
+
+	return func() {
+		if b.conf.OnStateChange != nil {
+			b.conf.OnStateChange(b.conf.Name, from, to)
+		}
+	}
+}
+
+// ratios calculates the ratio of failed and slow calls in window, it must
+// be called with b.mu held.
+func (b *Breaker) ratios() (requests int64, errorRatio, slowRatio float64) {
+	requests = b.requests.Sum()
+	if requests == 0 {
+		return 0, 0, 0
+	}
+
+	return requests,
+		float64(b.failures.Sum()) / float64(requests),
+		float64(b.slows.Sum()) / float64(requests)
+}
+
+// tripped reports whether the closed breaker should be opened, it must be
+// called with b.mu held.
+func (b *Breaker) tripped() bool {
+	requests, errorRatio, slowRatio := b.ratios()
+	if requests < b.conf.MinRequests {
+		return false
+	}
+
+	if errorRatio >= b.conf.ErrorRatio ||
+		(b.conf.SlowCallDuration > 0 && slowRatio >= b.conf.SlowCallRatio) {
+		b.errorRatio, b.slowRatio = errorRatio, slowRatio
+		return true
+	}
+
+	return false
+}
+
+// opened creates the error about the breaker is open, it must be called
+// with b.mu held.
+func (b *Breaker) opened(retryAfter time.Duration) *limit.LimitError {
+	return &limit.LimitError{
+		Limiter: b.conf.Name,
+		Reason:  limit.ReasonCircuitOpen,
+		Observed: map[string]float64{
+			"state":       float64(b.state),
+			"error_ratio": b.errorRatio,
+			"slow_ratio":  b.slowRatio,
+		},
+		RetryAfter: retryAfter,
+	}
+}
+
+// Stat tasks a snapshot of the breaker.
+func (b *Breaker) Stat() statForDebug {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	requests, errorRatio, slowRatio := b.ratios()
+	return statForDebug{
+		State:      b.state,
+		Requests:   requests,
+		Failures:   b.failures.Sum(),
+		SlowCalls:  b.slows.Sum(),
+		ErrorRatio: errorRatio,
+		SlowRatio:  slowRatio,
+	}
+}
+
+// State returns the current state of breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// Allow checks the call could pass the breaker, the outcome of call must be
+// reported by the returned function.
+func (b *Breaker) Allow(ctx context.Context, opts ...limit.AllowOption) (func(info limit.DoneInfo), error) {
+	allowOpts := limit.DefaultAllowOpts()
+	for _, opt := range opts {
+		opt.Apply(&allowOpts)
+	}
+
+	if allowOpts.Expired() {
+		return nil, context.DeadlineExceeded
+	}
+
+	now := time.Now()
+	notify := func() {}
+
+	b.mu.Lock()
+	if b.state == StateOpen {
+		elapsed := now.Sub(b.openedAt)
+		if elapsed < b.conf.OpenTimeout {
+			err := b.opened(b.conf.OpenTimeout - elapsed)
+			b.mu.Unlock()
+			return nil, err
+		}
+		notify = b.transit(StateHalfOpen, now)
+	}
+
+	if b.state == StateHalfOpen {
+		if b.probes >= b.conf.HalfOpenProbes {
+			err := b.opened(0)
+			b.mu.Unlock()
+			notify()
+			return nil, err
+		}
+		b.probes++
+	}
+	generation := b.generation
+	b.mu.Unlock()
+	notify()
+
+	return func(info limit.DoneInfo) {
+		b.done(generation, info, time.Since(now))
+	}, nil
+}
+
+// done records the outcome of call allowed in generation.
+func (b *Breaker) done(generation uint64, info limit.DoneInfo, rt time.Duration) {
+	failed := info.Err != nil || info.Op == limit.Drop
+	slow := b.conf.SlowCallDuration > 0 && rt >= b.conf.SlowCallDuration
+	notify := func() {}
+
+	b.mu.Lock()
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+
+	switch b.state {
+	case StateClosed:
+		if info.Op == limit.Ignore {
+			break
+		}
+		b.requests.Add(1)
+		if failed {
+			b.failures.Add(1)
+		}
+		if slow {
+			b.slows.Add(1)
+		}
+		if b.tripped() {
+			notify = b.transit(StateOpen, time.Now())
+		}
+	case StateHalfOpen:
+		switch {
+		case info.Op == limit.Ignore:
+			// release the probe.
+			b.probes--
+		case failed || slow:
+			b.errorRatio, b.slowRatio = 0, 0
+			if failed {
+				b.errorRatio = 1
+			}
+			if slow {
+				b.slowRatio = 1
+			}
+			notify = b.transit(StateOpen, time.Now())
+		default:
+			b.succeeded++
+			if b.succeeded >= b.conf.HalfOpenProbes {
+				notify = b.transit(StateClosed, time.Now())
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	notify()
+}
@@ -0,0 +1,131 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yeqown/ratelimit"
+)
+
+var errFailed = errors.New("failed")
+
+// call allows a call by b and reports info, false if it's rejected.
+func call(b *Breaker, info ratelimit.DoneInfo) bool {
+	done, err := b.Allow(context.Background())
+	if err != nil {
+		return false
+	}
+	done(info)
+	return true
+}
+
+func TestNew(t *testing.T) {
+	b := New(nil).(*Breaker)
+
+	assert.Equal(t, "breaker", b.conf.Name)
+	assert.Equal(t, 0.5, b.conf.ErrorRatio)
+	assert.Equal(t, int64(5), b.conf.HalfOpenProbes)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreaker_states(t *testing.T) {
+	var changes []string
+	b := New(&Config{
+		MinRequests:    10,
+		OpenTimeout:    20 * time.Millisecond,
+		HalfOpenProbes: 2,
+		OnStateChange: func(name string, from, to State) {
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+	}).(*Breaker)
+
+	// ignored calls are not counted.
+	for i := 0; i < 10; i++ {
+		assert.True(t, call(b, ratelimit.DoneInfo{Op: ratelimit.Ignore}))
+	}
+	for i := 0; i < 5; i++ {
+		assert.True(t, call(b, ratelimit.DoneInfo{Op: ratelimit.Success}))
+	}
+	for i := 0; i < 4; i++ {
+		assert.True(t, call(b, ratelimit.DoneInfo{Err: errFailed, Op: ratelimit.Success}))
+	}
+	assert.Equal(t, StateClosed, b.State())
+
+	// 5 of 10 calls failed.
+	assert.True(t, call(b, ratelimit.DoneInfo{Op: ratelimit.Drop}))
+	assert.Equal(t, StateOpen, b.State())
+
+	_, err := b.Allow(context.Background())
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	le := err.(*ratelimit.LimitError)
+	assert.Equal(t, "breaker", le.Limiter)
+	assert.Equal(t, ratelimit.ReasonCircuitOpen, le.Reason)
+	assert.Equal(t, 0.5, le.Observed["error_ratio"])
+	assert.True(t, le.RetryAfter > 0 && le.RetryAfter <= 20*time.Millisecond)
+
+	// half-open, only 2 probes are allowed, and a failed probe opens the
+	// breaker again.
+	time.Sleep(25 * time.Millisecond)
+	done1, err := b.Allow(context.Background())
+	assert.NoError(t, err)
+	done2, err := b.Allow(context.Background())
+	assert.NoError(t, err)
+	_, err = b.Allow(context.Background())
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	assert.Equal(t, StateHalfOpen, b.State())
+	done1(ratelimit.DoneInfo{Op: ratelimit.Success})
+	done2(ratelimit.DoneInfo{Err: errFailed})
+	assert.Equal(t, StateOpen, b.State())
+
+	// all probes succeed.
+	time.Sleep(25 * time.Millisecond)
+	assert.True(t, call(b, ratelimit.DoneInfo{Op: ratelimit.Success}))
+	assert.True(t, call(b, ratelimit.DoneInfo{Op: ratelimit.Success}))
+	assert.Equal(t, StateClosed, b.State())
+	assert.Equal(t, int64(0), b.Stat().Requests)
+
+	assert.Equal(t, []string{
+		"closed->open", "open->half-open", "half-open->open",
+		"open->half-open", "half-open->closed",
+	}, changes)
+}
+
+func TestBreaker_slowCalls(t *testing.T) {
+	b := New(&Config{
+		MinRequests:      4,
+		SlowCallDuration: 5 * time.Millisecond,
+		SlowCallRatio:    0.5,
+	}).(*Breaker)
+
+	for i := 0; i < 2; i++ {
+		assert.True(t, call(b, ratelimit.DoneInfo{Op: ratelimit.Success}))
+	}
+	for i := 0; i < 2; i++ {
+		done, err := b.Allow(context.Background())
+		assert.NoError(t, err)
+		time.Sleep(6 * time.Millisecond)
+		done(ratelimit.DoneInfo{Op: ratelimit.Success})
+	}
+
+	stat := b.Stat()
+	assert.Equal(t, StateOpen, stat.State)
+	assert.Equal(t, int64(0), stat.Failures)
+	assert.Equal(t, int64(2), stat.SlowCalls)
+}
+
+func TestBreaker_generation(t *testing.T) {
+	b := New(&Config{MinRequests: 1}).(*Breaker)
+
+	done, err := b.Allow(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, call(b, ratelimit.DoneInfo{Err: errFailed}))
+	assert.Equal(t, StateOpen, b.State())
+
+	// the call allowed before opening is not counted.
+	done(ratelimit.DoneInfo{Op: ratelimit.Success})
+	assert.Equal(t, int64(1), b.Stat().Requests)
+}
@@ -0,0 +1,84 @@
+package breaker
+
+import "time"
+
+var (
+	defaultConf = &Config{
+		Name:           "breaker",
+		Window:         time.Second * 10,
+		WinBucket:      100,
+		MinRequests:    20,
+		ErrorRatio:     0.5,
+		SlowCallRatio:  0.5,
+		OpenTimeout:    time.Second * 5,
+		HalfOpenProbes: 5,
+	}
+)
+
+// Config contains configs of circuit breaker.
+type Config struct {
+	// Name of the limiter, it's reported in limit.LimitError.
+	Name string
+	// Window time.Duration of window contains.
+	Window time.Duration
+	// WinBucket indicates how many bucket the window holds.
+	WinBucket uint32
+	// MinRequests indicates the minimum count of calls in window before the
+	// breaker could be tripped.
+	MinRequests int64
+	// ErrorRatio trips the breaker once the ratio of failed calls in window
+	// reaches it. A call fails if it's done with error or limit.Drop.
+	ErrorRatio float64
+	// SlowCallDuration indicates calls taking longer than it are slow,
+	// 0 means slow calls are not tracked.
+	SlowCallDuration time.Duration
+	// SlowCallRatio trips the breaker once the ratio of slow calls in window
+	// reaches it, it works only if SlowCallDuration is set.
+	SlowCallRatio float64
+	// OpenTimeout indicates how long the breaker keeps open before it turns
+	// to half-open.
+	OpenTimeout time.Duration
+	// HalfOpenProbes indicates how many calls are allowed to probe while
+	// half-open, the breaker is closed if all of them succeed, and opened
+	// again once any of them fails.
+	HalfOpenProbes int64
+	// OnStateChange is called after the state of breaker changes.
+	OnStateChange func(name string, from, to State)
+}
+
+func compatibleConfig(conf *Config) *Config {
+	if conf == nil {
+		c := *defaultConf
+		conf = &c
+	}
+
+	if conf.Name == "" {
+		conf.Name = defaultConf.Name
+	}
+	if conf.Window <= 0 {
+		conf.Window = defaultConf.Window
+	}
+	if conf.WinBucket <= 0 {
+		conf.WinBucket = defaultConf.WinBucket
+	}
+	if conf.MinRequests <= 0 {
+		conf.MinRequests = defaultConf.MinRequests
+	}
+	if conf.ErrorRatio <= 0 || conf.ErrorRatio > 1 {
+		conf.ErrorRatio = defaultConf.ErrorRatio
+	}
+	if conf.SlowCallDuration < 0 {
+		conf.SlowCallDuration = defaultConf.SlowCallDuration
+	}
+	if conf.SlowCallRatio <= 0 || conf.SlowCallRatio > 1 {
+		conf.SlowCallRatio = defaultConf.SlowCallRatio
+	}
+	if conf.OpenTimeout <= 0 {
+		conf.OpenTimeout = defaultConf.OpenTimeout
+	}
+	if conf.HalfOpenProbes <= 0 {
+		conf.HalfOpenProbes = defaultConf.HalfOpenProbes
+	}
+
+	return conf
+}
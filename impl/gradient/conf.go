@@ -0,0 +1,95 @@
+package gradient
+
+import "time"
+
+var (
+	defaultConf = &Config{
+		Name:           "gradient",
+		InitialLimit:   20,
+		MinLimit:       20,
+		MaxLimit:       200,
+		Smoothing:      0.2,
+		Tolerance:      1.5,
+		QueueSize:      func(limit int64) int64 { return 4 },
+		LongWindow:     600,
+		ShortWindow:    time.Second,
+		ShortWinBucket: 10,
+	}
+)
+
+// Config contains configs of gradient limiter.
+type Config struct {
+	// Name of the limiter, it's reported in limit.LimitError.
+	Name string
+	// InitialLimit indicates the concurrency limit at the beginning.
+	InitialLimit int64
+	// MinLimit indicates the minimum concurrency limit.
+	MinLimit int64
+	// MaxLimit indicates the maximum concurrency limit.
+	MaxLimit int64
+	// Smoothing indicates how fast the limit follows the new estimated
+	// limit, it's in (0, 1], higher is faster.
+	Smoothing float64
+	// Tolerance indicates how much the short-term RTT could be inflated over
+	// the long-term RTT before the limit is decreased, it's 1 at least.
+	// default is 1.5.
+	Tolerance float64
+	// QueueSize returns how many requests are allowed to queue above the
+	// estimated limit, it also lets the limit grow. default is 4.
+	QueueSize func(limit int64) int64
+	// LongWindow indicates how many samples the long-term RTT averages.
+	LongWindow int64
+	// ShortWindow time.Duration of window the short-term RTT averages.
+	ShortWindow time.Duration
+	// ShortWinBucket indicates how many bucket the short window holds.
+	ShortWinBucket uint32
+}
+
+func compatibleConfig(conf *Config) *Config {
+	if conf == nil {
+		c := *defaultConf
+		conf = &c
+	}
+
+	if conf.Name == "" {
+		conf.Name = defaultConf.Name
+	}
+	if conf.MinLimit <= 0 {
+		conf.MinLimit = defaultConf.MinLimit
+	}
+	if conf.MaxLimit <= 0 {
+		conf.MaxLimit = defaultConf.MaxLimit
+	}
+	if conf.MaxLimit < conf.MinLimit {
+		conf.MaxLimit = conf.MinLimit
+	}
+	if conf.InitialLimit <= 0 {
+		conf.InitialLimit = defaultConf.InitialLimit
+	}
+	if conf.InitialLimit < conf.MinLimit {
+		conf.InitialLimit = conf.MinLimit
+	}
+	if conf.InitialLimit > conf.MaxLimit {
+		conf.InitialLimit = conf.MaxLimit
+	}
+	if conf.Smoothing <= 0 || conf.Smoothing > 1 {
+		conf.Smoothing = defaultConf.Smoothing
+	}
+	if conf.Tolerance < 1 {
+		conf.Tolerance = defaultConf.Tolerance
+	}
+	if conf.QueueSize == nil {
+		conf.QueueSize = defaultConf.QueueSize
+	}
+	if conf.LongWindow <= 0 {
+		conf.LongWindow = defaultConf.LongWindow
+	}
+	if conf.ShortWindow <= 0 {
+		conf.ShortWindow = defaultConf.ShortWindow
+	}
+	if conf.ShortWinBucket <= 0 {
+		conf.ShortWinBucket = defaultConf.ShortWinBucket
+	}
+
+	return conf
+}
@@ -0,0 +1,160 @@
+package gradient
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	limit "github.com/yeqown/ratelimit"
+	"github.com/yeqown/ratelimit/internal/concurrency"
+	"github.com/yeqown/ratelimit/window"
+)
+
+// _WarmupSamples indicates the long-term RTT is the simple average of
+// samples before there are enough samples.
+const _WarmupSamples = 10
+
+// Gradient implements adaptive concurrency limiter like Gradient2 of
+// Netflix's concurrency-limits. The long-term RTT is the exponential
+// average of RT samples, and the short-term RTT is the average RT in the
+// short window. Once the short-term RTT is inflated over the long-term RTT
+// by more than Tolerance, the limit is decreased by the gradient:
+//
+//	gradient = max(0.5, min(1.0, Tolerance * longRTT / shortRTT))
+//	newLimit = limit * gradient + QueueSize(limit)
+//	limit    = limit * (1 - Smoothing) + newLimit * Smoothing
+//
+// Dropped requests are counted as the minimum gradient 0.5. It reacts to
+// latency inflation even when CPU is fine, such as a slow database.
+//
+// https://github.com/Netflix/concurrency-limits
+type Gradient struct {
+	conf *Config
+	// gate admits requests while requests in flight are under limit.
+	gate *concurrency.Gate
+
+	// mu for limit and longRTT safety while concurrent visiting.
+	mu sync.Mutex
+	// limit the concurrency limit.
+	limit float64
+	// longRTT the long-term RTT, the unit is time.Microsecond.
+	longRTT float64
+	// samples count of samples averaged by longRTT, up to _WarmupSamples.
+	samples int64
+	// shortRTT RT of requests in short window, the unit is time.Microsecond.
+	shortRTT *window.RollingCounter
+}
+
+// statForDebug contains the metrics' snapshot of gradient.
+type statForDebug struct {
+	Limit    int64
+	InFlight int64
+	LongRTT  time.Duration
+	ShortRTT time.Duration
+}
+
+var _ limit.Limiter = (*Gradient)(nil)
+
+// New create a gradient limiter.
+func New(conf *Config) limit.Limiter {
+	conf = compatibleConfig(conf)
+
+	l := &Gradient{
+		conf:  conf,
+		gate:  concurrency.NewGate(conf.Name),
+		limit: float64(conf.InitialLimit),
+		shortRTT: window.NewRollingCounter(conf.ShortWinBucket,
+			conf.ShortWindow/time.Duration(conf.ShortWinBucket)),
+	}
+
+	return l
+}
+
+// Limit returns the current concurrency limit.
+func (l *Gradient) Limit() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int64(l.limit)
+}
+
+// updateLongRTT averages rt into longRTT, it must be called with l.mu held.
+func (l *Gradient) updateLongRTT(rt float64) {
+	if l.samples < _WarmupSamples {
+		l.samples++
+		l.longRTT += (rt - l.longRTT) / float64(l.samples)
+		return
+	}
+
+	factor := 2 / float64(l.conf.LongWindow+1)
+	l.longRTT = l.longRTT*(1-factor) + rt*factor
+}
+
+// update adjusts the limit by the RT sample, inflight is the count of
+// requests in flight when the request was allowed.
+func (l *Gradient) update(rt time.Duration, inflight int64, dropped bool) {
+	l.shortRTT.Add(int64(rt / time.Microsecond))
+	shortRTT := math.Max(1, l.shortRTT.Avg())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.updateLongRTT(math.Max(1, float64(rt/time.Microsecond)))
+	// the long-term RTT drifts higher after a long period of high latency,
+	// recover it faster once latency drops.
+	if l.longRTT/shortRTT > 2 {
+		l.longRTT *= 0.95
+	}
+
+	// do not change the limit while it's not well used, or the limit
+	// would grow unbounded under low traffic.
+	if float64(inflight)*2 < l.limit && !dropped {
+		return
+	}
+
+	gradient := math.Max(0.5, math.Min(1.0, l.conf.Tolerance*l.longRTT/shortRTT))
+	if dropped {
+		gradient = 0.5
+	}
+
+	newLimit := l.limit*gradient + float64(l.conf.QueueSize(int64(l.limit)))
+	newLimit = l.limit*(1-l.conf.Smoothing) + newLimit*l.conf.Smoothing
+	l.limit = math.Max(float64(l.conf.MinLimit), math.Min(float64(l.conf.MaxLimit), newLimit))
+}
+
+// shortAvgRT returns the short-term RTT.
+func (l *Gradient) shortAvgRT() time.Duration {
+	return time.Duration(l.shortRTT.Avg()) * time.Microsecond
+}
+
+// Stat tasks a snapshot of the gradient limiter.
+func (l *Gradient) Stat() statForDebug {
+	l.mu.Lock()
+	lim, longRTT := int64(l.limit), l.longRTT
+	l.mu.Unlock()
+
+	return statForDebug{
+		Limit:    lim,
+		InFlight: l.gate.InFlight(),
+		LongRTT:  time.Duration(longRTT) * time.Microsecond,
+		ShortRTT: l.shortAvgRT(),
+	}
+}
+
+// Allow checks requests in flight are under the limit or not.
+func (l *Gradient) Allow(ctx context.Context, opts ...limit.AllowOption) (func(info limit.DoneInfo), error) {
+	allowOpts := limit.DefaultAllowOpts()
+	for _, opt := range opts {
+		opt.Apply(&allowOpts)
+	}
+
+	if allowOpts.Expired() {
+		return nil, context.DeadlineExceeded
+	}
+
+	return l.gate.Allow(l.Limit(), allowOpts.Cost, l.shortAvgRT,
+		func(info limit.DoneInfo, rt time.Duration, inflight int64) {
+			l.update(rt, inflight, info.Op == limit.Drop)
+		})
+}
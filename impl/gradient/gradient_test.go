@@ -0,0 +1,60 @@
+package gradient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yeqown/ratelimit"
+)
+
+func TestNew(t *testing.T) {
+	l := New(nil).(*Gradient)
+
+	assert.Equal(t, int64(20), l.Limit())
+	assert.Equal(t, int64(4), l.conf.QueueSize(20))
+	assert.Equal(t, 1.5, l.conf.Tolerance)
+}
+
+func TestGradient_Allow(t *testing.T) {
+	l := New(&Config{InitialLimit: 1, MinLimit: 1}).(*Gradient)
+
+	done, err := l.Allow(context.Background())
+	assert.NoError(t, err)
+
+	// limit = 1 * 0.8 + (1 * 1.0 + 4) * 0.2 = 1.8
+	done(ratelimit.DoneInfo{Op: ratelimit.Success})
+	assert.Equal(t, int64(0), l.Stat().InFlight)
+	assert.InDelta(t, 1.8, l.limit, 1e-9)
+}
+
+func TestGradient_update(t *testing.T) {
+	l := New(&Config{InitialLimit: 50, MinLimit: 10, MaxLimit: 100}).(*Gradient)
+
+	// not well used, the limit is kept.
+	l.update(10*time.Millisecond, 10, false)
+	assert.Equal(t, int64(50), l.Limit())
+	assert.Equal(t, 10*time.Millisecond, l.Stat().LongRTT)
+
+	// RT is stable, the limit grows by queue size.
+	for i := 0; i < 100; i++ {
+		l.update(10*time.Millisecond, 100, false)
+	}
+	assert.Equal(t, int64(100), l.Limit())
+
+	// RT is inflated over tolerance, the limit shrinks.
+	for i := 0; i < 100; i++ {
+		l.update(100*time.Millisecond, 100, false)
+	}
+	stat := l.Stat()
+	assert.True(t, float64(stat.ShortRTT) > 1.5*float64(stat.LongRTT), "stat=%+v", stat)
+	assert.True(t, stat.Limit < 80, "stat=%+v", stat)
+
+	// dropped request halves the gradient even if it's not well used.
+	l = New(&Config{InitialLimit: 50, MinLimit: 10}).(*Gradient)
+	l.update(10*time.Millisecond, 0, true)
+	// 50 * 0.8 + (50 * 0.5 + 4) * 0.2 = 45.8
+	assert.InDelta(t, 45.8, l.limit, 1e-9)
+}
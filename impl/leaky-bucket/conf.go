@@ -0,0 +1,39 @@
+package leakybucket
+
+var (
+	defaultConf = &Config{
+		Name:     "leakybucket",
+		Rate:     100,
+		Capacity: 100,
+	}
+)
+
+// Config contains configs of leaky bucket limiter.
+type Config struct {
+	// Name of the limiter, it's reported in limit.LimitError.
+	Name string
+	// Rate indicates how many requests would leak out from bucket per second.
+	Rate float64
+	// Capacity indicates how many requests could be parked in the bucket
+	// (queue) waiting for their turn.
+	Capacity int64
+}
+
+func compatibleConfig(conf *Config) *Config {
+	if conf == nil {
+		c := *defaultConf
+		conf = &c
+	}
+
+	if conf.Name == "" {
+		conf.Name = defaultConf.Name
+	}
+	if conf.Rate <= 0 {
+		conf.Rate = defaultConf.Rate
+	}
+	if conf.Capacity <= 0 {
+		conf.Capacity = defaultConf.Capacity
+	}
+
+	return conf
+}
@@ -0,0 +1,210 @@
+package leakybucket
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	limit "github.com/yeqown/ratelimit"
+)
+
+// LeakyBucket implements queueing leaky bucket limiter. Requests are parked
+// in the bucket and leak out in a constant rate, so bursts are smoothed into
+// constant outflow. Only when the bucket is full, requests would be limited.
+//
+// https://en.wikipedia.org/wiki/Leaky_bucket#As_a_queue
+type LeakyBucket struct {
+	conf *Config
+
+	// interval time gap between two requests leak out.
+	interval time.Duration
+
+	// mu for last safety while concurrent visiting.
+	mu sync.Mutex
+	// last the time of the latest scheduled slot, requests scheduled
+	// after now are those parked in queue.
+	last time.Time
+}
+
+var (
+	_ limit.Limiter = (*LeakyBucket)(nil)
+	_ limit.Waiter  = (*LeakyBucket)(nil)
+)
+
+// New create a leaky bucket limiter.
+func New(conf *Config) limit.Limiter {
+	conf = compatibleConfig(conf)
+
+	l := &LeakyBucket{
+		conf:     conf,
+		interval: time.Duration(float64(time.Second) / conf.Rate),
+	}
+
+	return l
+}
+
+// reservation holds slots scheduled in LeakyBucket.
+type reservation struct {
+	l  *LeakyBucket
+	ok bool
+	// n count of slots reserved.
+	n int64
+	// first the time of the first reserved slot, the caller could leak out.
+	first time.Time
+	// end the time of the last reserved slot.
+	end time.Time
+	// retry how long to wait until the queue has room, only if it's not OK.
+	retry time.Duration
+}
+
+func (r *reservation) OK() bool {
+	return r.ok
+}
+
+func (r *reservation) Delay() time.Duration {
+	if !r.ok {
+		return 0
+	}
+	return time.Until(r.first)
+}
+
+// Cancel gives back the slots to bucket. Only the latest slots could be taken
+// back, otherwise the slots are wasted since requests behind them have been
+// scheduled.
+func (r *reservation) Cancel() {
+	if !r.ok {
+		return
+	}
+
+	r.l.mu.Lock()
+	if r.l.last.Equal(r.end) {
+		r.l.last = r.end.Add(-time.Duration(r.n) * r.l.interval)
+	}
+	r.l.mu.Unlock()
+}
+
+// Reserve schedules n continuous slots in queue, the caller should leak out
+// after Delay. The reservation is not OK if the queue is full.
+func (l *LeakyBucket) Reserve(n int64) limit.Reservation {
+	if n <= 0 {
+		n = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	first := l.last.Add(l.interval)
+	if first.Before(now) {
+		first = now
+	}
+	end := first.Add(time.Duration(n-1) * l.interval)
+
+	if over := end.Sub(now) - time.Duration(l.conf.Capacity)*l.interval; over > 0 {
+		// slots more than the queue could never be reserved, retrying is
+		// meaningless.
+		if n > l.conf.Capacity+1 {
+			over = 0
+		}
+		return &reservation{l: l, ok: false, n: n, retry: over}
+	}
+	l.last = end
+
+	return &reservation{
+		l:     l,
+		ok:    true,
+		n:     n,
+		first: first,
+		end:   end,
+	}
+}
+
+// Wait blocks until n slots come up or ctx is done.
+func (l *LeakyBucket) Wait(ctx context.Context, n int64) error {
+	_, err := l.wait(ctx, n)
+	return err
+}
+
+// wait blocks until n slots come up or ctx is done, the reservation of
+// slots is returned so that they could be given back.
+func (l *LeakyBucket) wait(ctx context.Context, n int64) (*reservation, error) {
+	r := l.Reserve(n).(*reservation)
+	if !r.OK() {
+		return nil, &limit.LimitError{
+			Limiter: l.conf.Name,
+			Reason:  limit.ReasonQueueFull,
+			Observed: map[string]float64{
+				"waiting":  float64(l.Stat().Waiting),
+				"need":     float64(r.n),
+				"capacity": float64(l.conf.Capacity),
+			},
+			RetryAfter: r.retry,
+		}
+	}
+
+	err := limit.WaitReservation(ctx, r)
+	if le, ok := err.(*limit.LimitError); ok {
+		le.Limiter = l.conf.Name
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// statForDebug contains the metrics' snapshot of leaky bucket.
+type statForDebug struct {
+	Waiting  int64   // count of requests parked in queue
+	Capacity int64   // capacity of queue
+	Rate     float64 // requests leak out per second
+}
+
+// Stat tasks a snapshot of the leaky bucket limiter.
+func (l *LeakyBucket) Stat() statForDebug {
+	l.mu.Lock()
+	wait := time.Until(l.last)
+	l.mu.Unlock()
+
+	waiting := int64(0)
+	if wait > 0 {
+		waiting = int64((wait + l.interval - 1) / l.interval)
+	}
+
+	return statForDebug{
+		Waiting:  waiting,
+		Capacity: l.conf.Capacity,
+		Rate:     l.conf.Rate,
+	}
+}
+
+// Allow parks inbound traffic in the queue until its turn comes up, the
+// request with limit.WithCost(n) takes n continuous slots.
+// Once the queue is full or deadline is earlier than the turn,
+// it raises *limit.LimitError which matches limit.ErrLimitExceed. If ctx is done while waiting,
+// ctx.Err() would be returned. Slots of requests done with limit.Ignore, such
+// as rolled back by limit.All, are given back if no request is scheduled
+// behind them.
+func (l *LeakyBucket) Allow(ctx context.Context, opts ...limit.AllowOption) (func(info limit.DoneInfo), error) {
+	allowOpts := limit.DefaultAllowOpts()
+	for _, opt := range opts {
+		opt.Apply(&allowOpts)
+	}
+
+	if !allowOpts.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, allowOpts.Deadline)
+		defer cancel()
+	}
+
+	r, err := l.wait(ctx, allowOpts.Cost)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(do limit.DoneInfo) {
+		if do.Op == limit.Ignore {
+			r.Cancel()
+		}
+	}, nil
+}
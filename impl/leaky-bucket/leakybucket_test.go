@@ -0,0 +1,164 @@
+package leakybucket
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yeqown/ratelimit"
+)
+
+func TestNew(t *testing.T) {
+	l := New(nil)
+
+	lb := l.(*LeakyBucket)
+	assert.Equal(t, float64(100), lb.conf.Rate)
+	assert.Equal(t, int64(100), lb.conf.Capacity)
+
+	// zero capacity means the default.
+	zero := New(&Config{Rate: 10}).(*LeakyBucket)
+	assert.Equal(t, int64(100), zero.conf.Capacity)
+	assert.Equal(t, 10*time.Millisecond, lb.interval)
+}
+
+func TestLeakyBucket_Allow(t *testing.T) {
+	l := New(&Config{Rate: 100, Capacity: 5})
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		passed   []time.Time
+		rejected int
+	)
+	wg.Add(10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer wg.Done()
+
+			done, err := l.Allow(context.Background())
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+				assert.Equal(t, ratelimit.ReasonQueueFull, err.(*ratelimit.LimitError).Reason)
+				assert.True(t, err.(*ratelimit.LimitError).RetryAfter > 0)
+				rejected++
+				return
+			}
+			passed = append(passed, time.Now())
+			done(ratelimit.DoneInfo{Op: ratelimit.Success})
+		}()
+	}
+	wg.Wait()
+
+	// 1 leaks out immediately, and 5 parked in queue.
+	assert.Equal(t, 6, len(passed))
+	assert.Equal(t, 4, rejected)
+
+	first, last := passed[0], passed[0]
+	for _, p := range passed {
+		if p.Before(first) {
+			first = p
+		}
+		if p.After(last) {
+			last = p
+		}
+	}
+	assert.True(t, last.Sub(first) >= 45*time.Millisecond, "outflow should be paced")
+}
+
+func TestLeakyBucket_Allow_ctx(t *testing.T) {
+	l := New(&Config{Rate: 10, Capacity: 5})
+
+	_, err := l.Allow(context.Background())
+	assert.NoError(t, err)
+
+	// next slot is 100ms later, but deadline is earlier.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = l.Allow(ctx)
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+
+	// cancel while waiting, the slot should be given back.
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel2()
+	}()
+	_, err = l.Allow(ctx2)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, int64(0), l.(*LeakyBucket).Stat().Waiting)
+
+	// slots given back could be taken by later requests.
+	ctx3, cancel3 := context.WithTimeout(context.Background(), 90*time.Millisecond)
+	defer cancel3()
+	_, err = l.Allow(ctx3)
+	assert.NoError(t, err)
+}
+
+func TestLeakyBucket_Reserve(t *testing.T) {
+	l := New(&Config{Rate: 100, Capacity: 5}).(*LeakyBucket)
+
+	r := l.Reserve(3)
+	assert.True(t, r.OK())
+	assert.True(t, r.Delay() <= 0)
+
+	// 2 slots are parked behind r, and 3 more slots fill the queue.
+	r2 := l.Reserve(3)
+	assert.True(t, r2.OK())
+	assert.True(t, r2.Delay() > 20*time.Millisecond)
+	assert.False(t, l.Reserve(1).OK())
+
+	r2.Cancel()
+	assert.True(t, l.Reserve(1).OK())
+
+	// slots more than the queue could never be reserved.
+	err := l.Wait(context.Background(), 7)
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	assert.Equal(t, time.Duration(0), err.(*ratelimit.LimitError).RetryAfter)
+}
+
+func TestLeakyBucket_Wait(t *testing.T) {
+	l := New(&Config{Rate: 100, Capacity: 10}).(*LeakyBucket)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, l.Wait(context.Background(), 1))
+	}
+	assert.True(t, time.Since(start) >= 35*time.Millisecond)
+}
+
+func TestLeakyBucket_Allow_options(t *testing.T) {
+	l := New(&Config{Rate: 10, Capacity: 5})
+
+	// 3 slots are taken, the next slot is 300ms later.
+	_, err := l.Allow(context.Background(), ratelimit.WithCost(3))
+	assert.NoError(t, err)
+
+	_, err = l.Allow(context.Background(), ratelimit.WithDeadline(time.Now().Add(100*time.Millisecond)))
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	assert.Equal(t, ratelimit.ReasonDeadline, err.(*ratelimit.LimitError).Reason)
+	assert.Equal(t, "leakybucket", err.(*ratelimit.LimitError).Limiter)
+	assert.Equal(t, int64(2), l.(*LeakyBucket).Stat().Waiting)
+}
+
+func TestLeakyBucket_Allow_ignore(t *testing.T) {
+	l := New(&Config{Rate: 10, Capacity: 5}).(*LeakyBucket)
+
+	// Any of nothing always rejects, so the slot taken by l is given back,
+	// and the next slot comes up at once rather than 100ms later.
+	_, err := ratelimit.All(l, ratelimit.Any()).Allow(context.Background())
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	r := l.Reserve(1)
+	assert.True(t, r.Delay() < 50*time.Millisecond)
+	r.Cancel()
+
+	done, err := l.Allow(context.Background())
+	assert.NoError(t, err)
+	done(ratelimit.DoneInfo{Op: ratelimit.Success})
+	assert.True(t, l.Reserve(1).Delay() > 50*time.Millisecond)
+}
@@ -0,0 +1,55 @@
+package sre
+
+import "time"
+
+var (
+	defaultConf = &Config{
+		Name:        "sre",
+		Window:      time.Second * 10,
+		WinBucket:   100,
+		K:           2.0,
+		MinRequests: 20,
+	}
+)
+
+// Config contains configs of sre limiter.
+type Config struct {
+	// Name of the limiter, it's reported in limit.LimitError.
+	Name string
+	// Window time.Duration of window contains.
+	Window time.Duration
+	// WinBucket indicates how many bucket the window holds.
+	WinBucket uint32
+	// K is the multiplier of accepts, requests are throttled once they're
+	// more than K * accepts. Lower K throttles more aggressively, default
+	// is 2.0 which is suggested by the SRE book.
+	K float64
+	// MinRequests indicates the minimum count of requests in window before
+	// throttling, it avoids throttling while there are too few samples.
+	MinRequests int64
+}
+
+func compatibleConfig(conf *Config) *Config {
+	if conf == nil {
+		c := *defaultConf
+		conf = &c
+	}
+
+	if conf.Name == "" {
+		conf.Name = defaultConf.Name
+	}
+	if conf.Window <= 0 {
+		conf.Window = defaultConf.Window
+	}
+	if conf.WinBucket <= 0 {
+		conf.WinBucket = defaultConf.WinBucket
+	}
+	if conf.K <= 0 {
+		conf.K = defaultConf.K
+	}
+	if conf.MinRequests <= 0 {
+		conf.MinRequests = defaultConf.MinRequests
+	}
+
+	return conf
+}
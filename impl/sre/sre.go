@@ -0,0 +1,117 @@
+package sre
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	limit "github.com/yeqown/ratelimit"
+	"github.com/yeqown/ratelimit/window"
+)
+
+// SRE implements the client-side adaptive throttling from the Google SRE
+// book. The client tracks requests and accepts by the backend in window,
+// once requests are more than K * accepts, new requests are rejected
+// locally with probability:
+//
+//	max(0, (requests - K * accepts) / (requests + 1))
+//
+// so that the overloaded backend could recover without any coordination.
+// A request is accepted if it's done with limit.Success, and it's not
+// counted if it's done with limit.Ignore.
+//
+// https://sre.google/sre-book/handling-overload/#eq2101
+type SRE struct {
+	conf *Config
+
+	requests *window.RollingCounter
+	accepts  *window.RollingCounter
+
+	// random returns a number in [0.0, 1.0), it's replaceable in test.
+	random func() float64
+}
+
+// statForDebug contains the metrics' snapshot of sre.
+type statForDebug struct {
+	Requests    int64
+	Accepts     int64
+	Probability float64 // the probability of rejecting new request
+}
+
+var _ limit.Limiter = (*SRE)(nil)
+
+// New create a sre limiter.
+func New(conf *Config) limit.Limiter {
+	conf = compatibleConfig(conf)
+
+	bucketDuration := conf.Window / time.Duration(conf.WinBucket)
+	l := &SRE{
+		conf:     conf,
+		requests: window.NewRollingCounter(conf.WinBucket, bucketDuration),
+		accepts:  window.NewRollingCounter(conf.WinBucket, bucketDuration),
+		random:   rand.Float64,
+	}
+
+	return l
+}
+
+// probability calculates the probability of rejecting new request by
+// requests and accepts in window.
+func (l *SRE) probability(requests, accepts int64) float64 {
+	if requests < l.conf.MinRequests {
+		return 0
+	}
+
+	p := (float64(requests) - l.conf.K*float64(accepts)) / float64(requests+1)
+	return math.Max(0, p)
+}
+
+// Stat tasks a snapshot of the sre limiter.
+func (l *SRE) Stat() statForDebug {
+	requests, accepts := l.requests.Sum(), l.accepts.Sum()
+
+	return statForDebug{
+		Requests:    requests,
+		Accepts:     accepts,
+		Probability: l.probability(requests, accepts),
+	}
+}
+
+// Allow checks the request is throttled or not, requests rejected locally
+// are counted as requests too.
+func (l *SRE) Allow(ctx context.Context, opts ...limit.AllowOption) (func(info limit.DoneInfo), error) {
+	allowOpts := limit.DefaultAllowOpts()
+	for _, opt := range opts {
+		opt.Apply(&allowOpts)
+	}
+
+	if allowOpts.Expired() {
+		return nil, context.DeadlineExceeded
+	}
+
+	requests, accepts := l.requests.Sum(), l.accepts.Sum()
+	if p := l.probability(requests, accepts); p > 0 && l.random() < p {
+		l.requests.Add(allowOpts.Cost)
+		return nil, &limit.LimitError{
+			Limiter: l.conf.Name,
+			Reason:  limit.ReasonThrottled,
+			Observed: map[string]float64{
+				"requests":    float64(requests),
+				"accepts":     float64(accepts),
+				"probability": p,
+			},
+			RetryAfter: l.conf.Window / time.Duration(l.conf.WinBucket),
+		}
+	}
+
+	return func(info limit.DoneInfo) {
+		switch info.Op {
+		case limit.Success:
+			l.requests.Add(allowOpts.Cost)
+			l.accepts.Add(allowOpts.Cost)
+		case limit.Drop:
+			l.requests.Add(allowOpts.Cost)
+		}
+	}, nil
+}
@@ -0,0 +1,76 @@
+package sre
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yeqown/ratelimit"
+)
+
+func TestNew(t *testing.T) {
+	l := New(nil).(*SRE)
+
+	assert.Equal(t, "sre", l.conf.Name)
+	assert.Equal(t, 2.0, l.conf.K)
+	assert.Equal(t, int64(20), l.conf.MinRequests)
+	assert.Equal(t, 10*time.Second, l.conf.Window)
+}
+
+func TestSRE_Allow(t *testing.T) {
+	l := New(&Config{MinRequests: 10}).(*SRE)
+	l.random = func() float64 { return 0.5 }
+
+	// backend accepts all requests.
+	for i := 0; i < 20; i++ {
+		done, err := l.Allow(context.Background())
+		assert.NoError(t, err)
+		done(ratelimit.DoneInfo{Op: ratelimit.Success})
+	}
+	assert.Equal(t, float64(0), l.Stat().Probability)
+
+	// ignored requests are not counted.
+	done, err := l.Allow(context.Background())
+	assert.NoError(t, err)
+	done(ratelimit.DoneInfo{Op: ratelimit.Ignore})
+	assert.Equal(t, int64(20), l.Stat().Requests)
+
+	// backend rejects requests, until requests > K * accepts and the
+	// probability is over random 0.5.
+	dropped := 0
+	for {
+		done, err := l.Allow(context.Background())
+		if err != nil {
+			break
+		}
+		done(ratelimit.DoneInfo{Op: ratelimit.Drop})
+		dropped++
+	}
+	// (20 + dropped - 2*20) / (20 + dropped + 1) > 0.5
+	assert.Equal(t, 62, dropped)
+
+	_, err = l.Allow(context.Background())
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	le := err.(*ratelimit.LimitError)
+	assert.Equal(t, "sre", le.Limiter)
+	assert.Equal(t, ratelimit.ReasonThrottled, le.Reason)
+	assert.Equal(t, float64(20), le.Observed["accepts"])
+	assert.Equal(t, 100*time.Millisecond, le.RetryAfter)
+
+	// rejected requests are counted as requests.
+	stat := l.Stat()
+	assert.Equal(t, int64(20+dropped+2), stat.Requests)
+	assert.Equal(t, int64(20), stat.Accepts)
+}
+
+func TestSRE_probability(t *testing.T) {
+	l := New(&Config{MinRequests: 10, K: 1.5}).(*SRE)
+
+	assert.Equal(t, float64(0), l.probability(9, 0))
+	assert.Equal(t, float64(0), l.probability(100, 80))
+	assert.InDelta(t, float64(10)/101, l.probability(100, 60), 1e-9)
+	assert.InDelta(t, float64(100)/101, l.probability(100, 0), 1e-9)
+}
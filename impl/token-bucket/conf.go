@@ -0,0 +1,45 @@
+package tokenbucket
+
+var (
+	defaultConf = &Config{
+		Name:             "tokenbucket",
+		Rate:             100,
+		Burst:            100,
+		TokensPerRequest: 1,
+	}
+)
+
+// Config contains configs of token bucket limiter.
+type Config struct {
+	// Name of the limiter, it's reported in limit.LimitError.
+	Name string
+	// Rate indicates how many tokens would be put into bucket per second.
+	Rate float64
+	// Burst indicates the capacity of the bucket, the maximum count of tokens
+	// could be consumed at once.
+	Burst int64
+	// TokensPerRequest indicates how many tokens one request consumes.
+	TokensPerRequest int64
+}
+
+func compatibleConfig(conf *Config) *Config {
+	if conf == nil {
+		c := *defaultConf
+		conf = &c
+	}
+
+	if conf.Name == "" {
+		conf.Name = defaultConf.Name
+	}
+	if conf.Rate <= 0 {
+		conf.Rate = defaultConf.Rate
+	}
+	if conf.Burst <= 0 {
+		conf.Burst = defaultConf.Burst
+	}
+	if conf.TokensPerRequest <= 0 {
+		conf.TokensPerRequest = defaultConf.TokensPerRequest
+	}
+
+	return conf
+}
@@ -0,0 +1,240 @@
+package tokenbucket
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	limit "github.com/yeqown/ratelimit"
+)
+
+// TokenBucket implements token bucket limiter. Tokens are put into bucket
+// in a fixed rate, and each request takes TokensPerRequest tokens away from
+// the bucket, once there are not enough tokens the request would be limited.
+//
+// https://en.wikipedia.org/wiki/Token_bucket
+type TokenBucket struct {
+	conf *Config
+
+	// mu for tokens and last safety while concurrent visiting.
+	mu sync.Mutex
+	// tokens count of available tokens in bucket, it's negative if
+	// tokens are owed by reservations.
+	tokens float64
+	// last the time of tokens last updated.
+	last time.Time
+}
+
+var (
+	_ limit.Limiter = (*TokenBucket)(nil)
+	_ limit.Waiter  = (*TokenBucket)(nil)
+)
+
+// New create a token bucket limiter, the bucket is full at the beginning.
+func New(conf *Config) limit.Limiter {
+	conf = compatibleConfig(conf)
+
+	l := &TokenBucket{
+		conf:   conf,
+		tokens: float64(conf.Burst),
+		last:   time.Now(),
+	}
+
+	return l
+}
+
+// advance puts tokens those generated since last into bucket,
+// but never more than conf.Burst. It must be called with l.mu held.
+func (l *TokenBucket) advance(now time.Time) {
+	elapsed := now.Sub(l.last)
+	if elapsed <= 0 {
+		return
+	}
+
+	l.tokens = math.Min(float64(l.conf.Burst), l.tokens+elapsed.Seconds()*l.conf.Rate)
+	l.last = now
+}
+
+// take tries to consume n tokens from bucket, returns false if there
+// are not enough tokens. tokens in bucket before taking is also returned.
+func (l *TokenBucket) take(n int64) (bool, float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.advance(time.Now())
+	tokens := l.tokens
+	if tokens < float64(n) {
+		return false, tokens
+	}
+	l.tokens -= float64(n)
+
+	return true, tokens
+}
+
+// refund puts tokens back into bucket, but never more than conf.Burst.
+func (l *TokenBucket) refund(tokens float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.advance(time.Now())
+	l.tokens = math.Min(float64(l.conf.Burst), l.tokens+tokens)
+}
+
+// exhausted creates the error about tokens exhausted, need tokens are
+// required but only tokens in bucket.
+func (l *TokenBucket) exhausted(need, tokens float64) *limit.LimitError {
+	err := &limit.LimitError{
+		Limiter: l.conf.Name,
+		Reason:  limit.ReasonTokensExhausted,
+		Observed: map[string]float64{
+			"tokens": tokens,
+			"need":   need,
+			"burst":  float64(l.conf.Burst),
+		},
+	}
+
+	// no retry could succeed if need is more than burst.
+	if need <= float64(l.conf.Burst) {
+		err.RetryAfter = time.Duration((need - tokens) / l.conf.Rate * float64(time.Second))
+	}
+
+	return err
+}
+
+// reservation holds tokens reserved from TokenBucket.
+type reservation struct {
+	l      *TokenBucket
+	ok     bool
+	tokens float64
+	// act the time when the tokens could be used.
+	act time.Time
+}
+
+func (r *reservation) OK() bool {
+	return r.ok
+}
+
+func (r *reservation) Delay() time.Duration {
+	if !r.ok {
+		return 0
+	}
+	return time.Until(r.act)
+}
+
+// Cancel puts the reserved tokens back into bucket, but only if they are
+// not used yet.
+func (r *reservation) Cancel() {
+	if !r.ok {
+		return
+	}
+
+	r.l.mu.Lock()
+	defer r.l.mu.Unlock()
+
+	now := time.Now()
+	if r.tokens == 0 || !now.Before(r.act) {
+		return
+	}
+	r.l.advance(now)
+	r.l.tokens = math.Min(float64(r.l.conf.Burst), r.l.tokens+r.tokens)
+	r.tokens = 0
+}
+
+// Reserve reserves n permits (n * TokensPerRequest tokens) from bucket.
+// Tokens in bucket could be owed by reservations, the reservation should
+// wait until the owed tokens are put back. n <= 0 is treated as 1.
+func (l *TokenBucket) Reserve(n int64) limit.Reservation {
+	if n <= 0 {
+		n = 1
+	}
+
+	need := float64(n * l.conf.TokensPerRequest)
+	if need > float64(l.conf.Burst) {
+		return &reservation{l: l, ok: false}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.advance(now)
+	l.tokens -= need
+
+	act := now
+	if l.tokens < 0 {
+		act = now.Add(time.Duration(-l.tokens / l.conf.Rate * float64(time.Second)))
+	}
+
+	return &reservation{
+		l:      l,
+		ok:     true,
+		tokens: need,
+		act:    act,
+	}
+}
+
+// Wait blocks until n permits are available or ctx is done.
+func (l *TokenBucket) Wait(ctx context.Context, n int64) error {
+	r := l.Reserve(n)
+	if !r.OK() {
+		return l.exhausted(float64(n*l.conf.TokensPerRequest), l.Stat().Tokens)
+	}
+
+	err := limit.WaitReservation(ctx, r)
+	if le, ok := err.(*limit.LimitError); ok {
+		le.Limiter = l.conf.Name
+	}
+
+	return err
+}
+
+// statForDebug contains the metrics' snapshot of token bucket.
+type statForDebug struct {
+	Tokens float64 // count of available tokens
+	Burst  int64   // capacity of bucket
+	Rate   float64 // tokens put into bucket per second
+}
+
+// Stat tasks a snapshot of the token bucket limiter.
+func (l *TokenBucket) Stat() statForDebug {
+	l.mu.Lock()
+	l.advance(time.Now())
+	tokens := l.tokens
+	l.mu.Unlock()
+
+	return statForDebug{
+		Tokens: tokens,
+		Burst:  l.conf.Burst,
+		Rate:   l.conf.Rate,
+	}
+}
+
+// Allow takes tokens from bucket for inbound traffic, the request with
+// limit.WithCost(n) takes n * TokensPerRequest tokens.
+// Once there are not enough tokens, it raises *limit.LimitError which
+// matches limit.ErrLimitExceed. Tokens of requests done with limit.Ignore,
+// such as rolled back by limit.All or canceled by client, are given back.
+func (l *TokenBucket) Allow(ctx context.Context, opts ...limit.AllowOption) (func(info limit.DoneInfo), error) {
+	allowOpts := limit.DefaultAllowOpts()
+	for _, opt := range opts {
+		opt.Apply(&allowOpts)
+	}
+
+	if allowOpts.Expired() {
+		return nil, context.DeadlineExceeded
+	}
+
+	need := allowOpts.Cost * l.conf.TokensPerRequest
+	if ok, tokens := l.take(need); !ok {
+		return nil, l.exhausted(float64(need), tokens)
+	}
+
+	// tokens are consumed once request is allowed, they're given back only
+	// if the request is ignored, such as rolled back by limit.All.
+	return func(do limit.DoneInfo) {
+		if do.Op == limit.Ignore {
+			l.refund(float64(need))
+		}
+	}, nil
+}
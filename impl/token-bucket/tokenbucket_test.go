@@ -0,0 +1,130 @@
+package tokenbucket
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yeqown/ratelimit"
+)
+
+func TestNew(t *testing.T) {
+	l := New(nil)
+
+	tb := l.(*TokenBucket)
+	assert.Equal(t, float64(100), tb.conf.Rate)
+	assert.Equal(t, int64(100), tb.conf.Burst)
+	assert.Equal(t, int64(1), tb.conf.TokensPerRequest)
+	assert.Equal(t, float64(100), tb.Stat().Tokens)
+}
+
+func TestTokenBucket_Allow(t *testing.T) {
+	l := New(&Config{Rate: 1, Burst: 10, TokensPerRequest: 2})
+
+	for i := 0; i < 5; i++ {
+		done, err := l.Allow(context.Background())
+		assert.NoError(t, err)
+		assert.NotNil(t, done)
+		done(ratelimit.DoneInfo{Op: ratelimit.Success})
+	}
+
+	_, err := l.Allow(context.Background())
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	le := err.(*ratelimit.LimitError)
+	assert.Equal(t, "tokenbucket", le.Limiter)
+	assert.Equal(t, ratelimit.ReasonTokensExhausted, le.Reason)
+	assert.InDelta(t, float64(2*time.Second), float64(le.RetryAfter), float64(100*time.Millisecond))
+	t.Logf("%+v", l.(*TokenBucket).Stat())
+}
+
+func TestTokenBucket_refill(t *testing.T) {
+	l := New(&Config{Rate: 100, Burst: 5, TokensPerRequest: 5})
+
+	_, err := l.Allow(context.Background())
+	assert.NoError(t, err)
+	_, err = l.Allow(context.Background())
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+
+	// 5 tokens need 50ms to refill.
+	time.Sleep(60 * time.Millisecond)
+	_, err = l.Allow(context.Background())
+	assert.NoError(t, err)
+
+	// tokens never exceed burst.
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, float64(5), l.(*TokenBucket).Stat().Tokens)
+}
+
+func TestTokenBucket_Reserve(t *testing.T) {
+	l := New(&Config{Rate: 100, Burst: 10, TokensPerRequest: 1}).(*TokenBucket)
+
+	// permits more than burst could never be reserved.
+	assert.False(t, l.Reserve(11).OK())
+
+	r := l.Reserve(10)
+	assert.True(t, r.OK())
+	assert.True(t, r.Delay() <= 0)
+
+	// 5 tokens are owed, 50ms to wait.
+	r2 := l.Reserve(5)
+	assert.True(t, r2.OK())
+	assert.InDelta(t, float64(50*time.Millisecond), float64(r2.Delay()), float64(10*time.Millisecond))
+	assert.True(t, l.Stat().Tokens < 0)
+
+	r2.Cancel()
+	assert.True(t, l.Stat().Tokens >= 0)
+
+	// non-positive permits never put tokens into bucket.
+	l = New(&Config{Rate: 1, Burst: 5, TokensPerRequest: 1}).(*TokenBucket)
+	assert.True(t, l.Reserve(5).OK())
+	assert.True(t, l.Reserve(-100).OK())
+	assert.True(t, l.Stat().Tokens < 0)
+	_, err := l.Allow(context.Background())
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+}
+
+func TestTokenBucket_Wait(t *testing.T) {
+	l := New(&Config{Rate: 100, Burst: 1, TokensPerRequest: 1}).(*TokenBucket)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, l.Wait(context.Background(), 1))
+	}
+	assert.True(t, time.Since(start) >= 35*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.NoError(t, l.Wait(context.Background(), 1))
+	assert.True(t, errors.Is(l.Wait(ctx, 1), ratelimit.ErrLimitExceed))
+}
+
+func TestTokenBucket_Allow_options(t *testing.T) {
+	l := New(&Config{Rate: 1, Burst: 10, TokensPerRequest: 2})
+
+	_, err := l.Allow(context.Background(), ratelimit.WithDeadline(time.Now().Add(-time.Second)))
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	_, err = l.Allow(context.Background(), ratelimit.WithCost(4))
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(2), l.(*TokenBucket).Stat().Tokens, 0.1)
+
+	_, err = l.Allow(context.Background(), ratelimit.WithCost(2))
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+}
+
+func TestTokenBucket_Allow_ignore(t *testing.T) {
+	l := New(&Config{Rate: 1, Burst: 10, TokensPerRequest: 1})
+
+	// Any of nothing always rejects, so tokens taken by l are given back.
+	_, err := ratelimit.All(l, ratelimit.Any()).Allow(context.Background(), ratelimit.WithCost(4))
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	assert.InDelta(t, float64(10), l.(*TokenBucket).Stat().Tokens, 0.1)
+
+	done, err := l.Allow(context.Background(), ratelimit.WithCost(4))
+	assert.NoError(t, err)
+	done(ratelimit.DoneInfo{Op: ratelimit.Success})
+	assert.InDelta(t, float64(6), l.(*TokenBucket).Stat().Tokens, 0.1)
+}
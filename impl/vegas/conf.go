@@ -0,0 +1,84 @@
+package vegas
+
+import "math"
+
+var (
+	defaultConf = &Config{
+		Name:            "vegas",
+		InitialLimit:    20,
+		MaxLimit:        1000,
+		Smoothing:       1.0,
+		Alpha:           func(limit int64) float64 { return 3 * log10Root(limit) },
+		Beta:            func(limit int64) float64 { return 6 * log10Root(limit) },
+		Threshold:       log10Root,
+		ProbeMultiplier: 30,
+	}
+)
+
+// log10Root returns log10 of limit, it's 1 at least.
+func log10Root(limit int64) float64 {
+	return math.Max(1, math.Floor(math.Log10(float64(limit))))
+}
+
+// Config contains configs of vegas limiter.
+type Config struct {
+	// Name of the limiter, it's reported in limit.LimitError.
+	Name string
+	// InitialLimit indicates the concurrency limit at the beginning.
+	InitialLimit int64
+	// MaxLimit indicates the maximum concurrency limit.
+	MaxLimit int64
+	// Smoothing indicates how fast the limit follows the new estimated
+	// limit, it's in (0, 1], higher is faster.
+	Smoothing float64
+	// Alpha returns the queue size under which the limit is increased,
+	// default is 3 * log10(limit).
+	Alpha func(limit int64) float64
+	// Beta returns the queue size over which the limit is decreased,
+	// default is 6 * log10(limit).
+	Beta func(limit int64) float64
+	// Threshold returns the queue size under which the limit is increased
+	// aggressively by Beta, default is log10(limit).
+	Threshold func(limit int64) float64
+	// ProbeMultiplier indicates minRTT is reset after every
+	// ProbeMultiplier * limit samples, so that it follows the latency
+	// changed by such as deployment.
+	ProbeMultiplier int64
+}
+
+func compatibleConfig(conf *Config) *Config {
+	if conf == nil {
+		c := *defaultConf
+		conf = &c
+	}
+
+	if conf.Name == "" {
+		conf.Name = defaultConf.Name
+	}
+	if conf.MaxLimit <= 0 {
+		conf.MaxLimit = defaultConf.MaxLimit
+	}
+	if conf.InitialLimit <= 0 {
+		conf.InitialLimit = defaultConf.InitialLimit
+	}
+	if conf.InitialLimit > conf.MaxLimit {
+		conf.InitialLimit = conf.MaxLimit
+	}
+	if conf.Smoothing <= 0 || conf.Smoothing > 1 {
+		conf.Smoothing = defaultConf.Smoothing
+	}
+	if conf.Alpha == nil {
+		conf.Alpha = defaultConf.Alpha
+	}
+	if conf.Beta == nil {
+		conf.Beta = defaultConf.Beta
+	}
+	if conf.Threshold == nil {
+		conf.Threshold = defaultConf.Threshold
+	}
+	if conf.ProbeMultiplier <= 0 {
+		conf.ProbeMultiplier = defaultConf.ProbeMultiplier
+	}
+
+	return conf
+}
@@ -0,0 +1,157 @@
+package vegas
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	limit "github.com/yeqown/ratelimit"
+	"github.com/yeqown/ratelimit/internal/concurrency"
+)
+
+// Vegas implements adaptive concurrency limiter like TCP Vegas. The queue
+// size is estimated by minRTT, which is the RTT without queueing, and the
+// RTT of each sample:
+//
+//	queue = limit * (1 - minRTT / sampleRTT)
+//
+// The limit is increased by Beta if queue is under Threshold, increased by
+// log10(limit) if queue is under Alpha, and decreased by log10(limit) if
+// queue is over Beta or the request is dropped.
+//
+// https://github.com/Netflix/concurrency-limits
+type Vegas struct {
+	conf *Config
+	// gate admits requests while requests in flight are under limit.
+	gate *concurrency.Gate
+
+	// mu for limit, minRTT and probe safety while concurrent visiting.
+	mu sync.Mutex
+	// limit the concurrency limit.
+	limit float64
+	// minRTT the RTT without queueing, 0 means there is no sample yet.
+	minRTT time.Duration
+	// samples count of samples since minRTT is reset.
+	samples int64
+}
+
+// statForDebug contains the metrics' snapshot of vegas.
+type statForDebug struct {
+	Limit    int64
+	InFlight int64
+	MinRTT   time.Duration
+}
+
+var _ limit.Limiter = (*Vegas)(nil)
+
+// New create a vegas limiter.
+func New(conf *Config) limit.Limiter {
+	conf = compatibleConfig(conf)
+
+	l := &Vegas{
+		conf:  conf,
+		gate:  concurrency.NewGate(conf.Name),
+		limit: float64(conf.InitialLimit),
+	}
+
+	return l
+}
+
+// Limit returns the current concurrency limit.
+func (l *Vegas) Limit() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int64(l.limit)
+}
+
+// update adjusts the limit by the RT sample, inflight is the count of
+// requests in flight when the request was allowed.
+func (l *Vegas) update(rt time.Duration, inflight int64, dropped bool) {
+	if rt <= 0 {
+		rt = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lim := int64(l.limit)
+
+	// reset minRTT periodically to probe the RTT without queueing.
+	l.samples++
+	if l.samples >= l.conf.ProbeMultiplier*lim {
+		l.samples = 0
+		l.minRTT = rt
+		return
+	}
+
+	if l.minRTT == 0 || rt < l.minRTT {
+		l.minRTT = rt
+		return
+	}
+
+	step := log10Root(lim)
+	var newLimit float64
+	switch {
+	case dropped:
+		newLimit = l.limit - step
+	case float64(inflight)*2 < l.limit:
+		// do not change the limit while it's not well used, or the limit
+		// would grow unbounded under low traffic.
+		return
+	default:
+		queue := math.Ceil(l.limit * (1 - float64(l.minRTT)/float64(rt)))
+		switch {
+		case queue <= l.conf.Threshold(lim):
+			newLimit = l.limit + l.conf.Beta(lim)
+		case queue < l.conf.Alpha(lim):
+			newLimit = l.limit + step
+		case queue > l.conf.Beta(lim):
+			newLimit = l.limit - step
+		default:
+			return
+		}
+	}
+
+	newLimit = math.Max(1, math.Min(float64(l.conf.MaxLimit), newLimit))
+	l.limit = l.limit*(1-l.conf.Smoothing) + newLimit*l.conf.Smoothing
+}
+
+// retryAfter suggests waiting for minRTT, the RTT without queueing.
+func (l *Vegas) retryAfter() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.minRTT
+}
+
+// Stat tasks a snapshot of the vegas limiter.
+func (l *Vegas) Stat() statForDebug {
+	l.mu.Lock()
+	lim, minRTT := int64(l.limit), l.minRTT
+	l.mu.Unlock()
+
+	return statForDebug{
+		Limit:    lim,
+		InFlight: l.gate.InFlight(),
+		MinRTT:   minRTT,
+	}
+}
+
+// Allow checks requests in flight are under the limit or not.
+func (l *Vegas) Allow(ctx context.Context, opts ...limit.AllowOption) (func(info limit.DoneInfo), error) {
+	allowOpts := limit.DefaultAllowOpts()
+	for _, opt := range opts {
+		opt.Apply(&allowOpts)
+	}
+
+	if allowOpts.Expired() {
+		return nil, context.DeadlineExceeded
+	}
+
+	return l.gate.Allow(l.Limit(), allowOpts.Cost, l.retryAfter,
+		func(info limit.DoneInfo, rt time.Duration, inflight int64) {
+			l.update(rt, inflight, info.Op == limit.Drop)
+		})
+}
@@ -0,0 +1,92 @@
+package vegas
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yeqown/ratelimit"
+)
+
+func TestNew(t *testing.T) {
+	l := New(nil).(*Vegas)
+
+	assert.Equal(t, int64(20), l.Limit())
+	assert.Equal(t, float64(3), l.conf.Alpha(20))
+	assert.Equal(t, float64(12), l.conf.Beta(100))
+	assert.Equal(t, float64(1), l.conf.Threshold(5))
+}
+
+func TestVegas_Allow(t *testing.T) {
+	l := New(&Config{InitialLimit: 1}).(*Vegas)
+
+	done, err := l.Allow(context.Background())
+	assert.NoError(t, err)
+
+	// the first sample is minRTT.
+	done(ratelimit.DoneInfo{Op: ratelimit.Success})
+	stat := l.Stat()
+	assert.Equal(t, int64(0), stat.InFlight)
+	assert.True(t, stat.MinRTT > 0)
+}
+
+func TestVegas_update(t *testing.T) {
+	l := New(nil).(*Vegas)
+	l.update(10*time.Millisecond, 20, false)
+	assert.Equal(t, 10*time.Millisecond, l.Stat().MinRTT)
+
+	// queue = 0, under threshold, limit = 20 + beta(20)
+	l.update(10*time.Millisecond, 20, false)
+	assert.Equal(t, int64(26), l.Limit())
+
+	// queue = 13, over beta(26)
+	l.update(20*time.Millisecond, 26, false)
+	assert.Equal(t, int64(25), l.Limit())
+
+	// queue = 3, between alpha(25) and beta(25)
+	l.update(11*time.Millisecond, 25, false)
+	assert.Equal(t, int64(25), l.Limit())
+
+	// queue = 2, under alpha(25)
+	l.update(10500*time.Microsecond, 25, false)
+	assert.Equal(t, int64(26), l.Limit())
+
+	// not well used.
+	l.update(10*time.Millisecond, 1, false)
+	assert.Equal(t, int64(26), l.Limit())
+
+	l.update(10*time.Millisecond, 1, true)
+	assert.Equal(t, int64(25), l.Limit())
+}
+
+func TestVegas_config(t *testing.T) {
+	l := New(&Config{
+		Alpha:     func(limit int64) float64 { return 10 },
+		Beta:      func(limit int64) float64 { return 20 },
+		Threshold: func(limit int64) float64 { return 0 },
+		Smoothing: 0.5,
+	}).(*Vegas)
+	l.update(10*time.Millisecond, 20, false)
+
+	// queue = 6, under alpha, limit = 20 * 0.5 + 21 * 0.5
+	l.update(14*time.Millisecond, 20, false)
+	assert.InDelta(t, 20.5, l.limit, 1e-9)
+
+	// queue = 0, under threshold, limit = 20.5 * 0.5 + 40.5 * 0.5
+	l.update(10*time.Millisecond, 20, false)
+	assert.InDelta(t, 30.5, l.limit, 1e-9)
+}
+
+func TestVegas_probe(t *testing.T) {
+	l := New(&Config{InitialLimit: 2, ProbeMultiplier: 1}).(*Vegas)
+
+	l.update(10*time.Millisecond, 2, false)
+	assert.Equal(t, 10*time.Millisecond, l.Stat().MinRTT)
+
+	// minRTT is reset after ProbeMultiplier * limit samples.
+	l.update(30*time.Millisecond, 2, false)
+	assert.Equal(t, 30*time.Millisecond, l.Stat().MinRTT)
+	assert.Equal(t, int64(2), l.Limit())
+}
@@ -0,0 +1,64 @@
+// Package concurrency provides the admission gate of concurrency limiters,
+// requests are admitted while requests in flight are under the limit.
+package concurrency
+
+import (
+	"sync/atomic"
+	"time"
+
+	limit "github.com/yeqown/ratelimit"
+)
+
+// Gate counts requests in flight and admits requests under the limit, the
+// limit is decided by the limiter, such as aimd, gradient or vegas.
+type Gate struct {
+	// name of the limiter, it's reported in limit.LimitError.
+	name string
+	// inflight requests in dealing.
+	inflight int64
+}
+
+// NewGate create a gate of limiter name.
+func NewGate(name string) *Gate {
+	return &Gate{name: name}
+}
+
+// InFlight returns the count of requests in flight.
+func (g *Gate) InFlight() int64 {
+	return atomic.LoadInt64(&g.inflight)
+}
+
+// Allow admits the request with cost if requests in flight are under lim,
+// the request is always admitted while nothing else is in flight. Once
+// it's rejected, retryAfter is called to suggest limit.LimitError.RetryAfter.
+//
+// onDone is called with the outcome, RT of the request and requests in
+// flight when it was admitted, except requests done with limit.Ignore, since
+// they tell nothing about the capacity.
+func (g *Gate) Allow(lim, cost int64, retryAfter func() time.Duration,
+	onDone func(info limit.DoneInfo, rt time.Duration, inflight int64)) (func(info limit.DoneInfo), error) {
+	inflight := atomic.AddInt64(&g.inflight, cost)
+	if inflight > lim && inflight > cost {
+		atomic.AddInt64(&g.inflight, -cost)
+		return nil, &limit.LimitError{
+			Limiter: g.name,
+			Reason:  limit.ReasonInflightOverload,
+			Observed: map[string]float64{
+				"inflight": float64(inflight - cost),
+				"limit":    float64(lim),
+			},
+			RetryAfter: retryAfter(),
+		}
+	}
+
+	start := time.Now()
+	return func(info limit.DoneInfo) {
+		rt := time.Since(start)
+		atomic.AddInt64(&g.inflight, -cost)
+		if info.Op == limit.Ignore {
+			return
+		}
+
+		onDone(info, rt, inflight)
+	}, nil
+}
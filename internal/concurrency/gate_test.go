@@ -0,0 +1,47 @@
+package concurrency
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yeqown/ratelimit"
+)
+
+func TestGate_Allow(t *testing.T) {
+	g := NewGate("mock")
+	retryAfter := func() time.Duration { return 10 * time.Millisecond }
+	var infos []ratelimit.DoneInfo
+	var inflights []int64
+	onDone := func(info ratelimit.DoneInfo, rt time.Duration, inflight int64) {
+		infos = append(infos, info)
+		inflights = append(inflights, inflight)
+	}
+
+	// the request is admitted while nothing else is in flight, even if
+	// its cost is over the limit.
+	done1, err := g.Allow(1, 3, retryAfter, onDone)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), g.InFlight())
+
+	_, err = g.Allow(3, 1, retryAfter, onDone)
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	le := err.(*ratelimit.LimitError)
+	assert.Equal(t, "mock", le.Limiter)
+	assert.Equal(t, ratelimit.ReasonInflightOverload, le.Reason)
+	assert.Equal(t, map[string]float64{"inflight": 3, "limit": 3}, le.Observed)
+	assert.Equal(t, 10*time.Millisecond, le.RetryAfter)
+	assert.Equal(t, int64(3), g.InFlight())
+
+	done2, err := g.Allow(5, 1, retryAfter, onDone)
+	assert.NoError(t, err)
+
+	// ignored requests are released but not reported.
+	done1(ratelimit.DoneInfo{Op: ratelimit.Ignore})
+	done2(ratelimit.DoneInfo{Op: ratelimit.Drop})
+	assert.Equal(t, int64(0), g.InFlight())
+	assert.Equal(t, []ratelimit.DoneInfo{{Op: ratelimit.Drop}}, infos)
+	assert.Equal(t, []int64{4}, inflights)
+}
@@ -8,11 +8,35 @@ import (
 	"path"
 	"strconv"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
 const cgroupRootDir = "/sys/fs/cgroup"
 
-// cgroup Linux cgroup
+// cgroupStats reads cpu stats of cgroup, both cgroup v1 and v2
+// (unified hierarchy) are supported.
+type cgroupStats interface {
+	// CPUQuota returns quota and period (microseconds) of cpu,
+	// quota is -1 if there is no limit.
+	CPUQuota() (quota int64, period uint64, err error)
+	// CPUUsage returns total cpu time (nanoseconds) consumed by cgroup.
+	CPUUsage() (uint64, error)
+	// CPUAcctUsagePerCPU returns cpu time (nanoseconds) consumed on each cpu.
+	CPUAcctUsagePerCPU() ([]uint64, error)
+	// CPUSetCPUs returns cpus could be used by cgroup.
+	CPUSetCPUs() ([]uint64, error)
+	// Memory returns memory usage and limit (bytes) of cgroup,
+	// limit is 0 if there is no limit.
+	Memory() (usage, limit uint64, err error)
+}
+
+var (
+	_ cgroupStats = (*cgroup)(nil)
+	_ cgroupStats = (*cgroupV2)(nil)
+)
+
+// cgroup Linux cgroup v1
 type cgroup struct {
 	cgroupSet map[string]string
 }
@@ -35,6 +59,22 @@ func (c *cgroup) CPUCFSPeriodUs() (uint64, error) {
 	return parseUint(data)
 }
 
+// CPUQuota cpu.cfs_quota_us and cpu.cfs_period_us
+func (c *cgroup) CPUQuota() (int64, uint64, error) {
+	quota, err := c.CPUCFSQuotaUs()
+	if err != nil {
+		return 0, 0, err
+	}
+	if quota == -1 {
+		return quota, 0, nil
+	}
+	period, err := c.CPUCFSPeriodUs()
+	if err != nil {
+		return 0, 0, err
+	}
+	return quota, period, nil
+}
+
 // CPUAcctUsage cpuacct.usage
 func (c *cgroup) CPUAcctUsage() (uint64, error) {
 	data, err := readFile(path.Join(c.cgroupSet["cpuacct"], "cpuacct.usage"))
@@ -44,6 +84,11 @@ func (c *cgroup) CPUAcctUsage() (uint64, error) {
 	return parseUint(data)
 }
 
+// CPUUsage cpuacct.usage
+func (c *cgroup) CPUUsage() (uint64, error) {
+	return c.CPUAcctUsage()
+}
+
 // CPUAcctUsagePerCPU cpuacct.usage_percpu
 func (c *cgroup) CPUAcctUsagePerCPU() ([]uint64, error) {
 	data, err := readFile(path.Join(c.cgroupSet["cpuacct"], "cpuacct.usage_percpu"))
@@ -70,6 +115,127 @@ func (c *cgroup) CPUSetCPUs() ([]uint64, error) {
 	if err != nil {
 		return nil, err
 	}
+	return parseCPUSet(data)
+}
+
+// _NoMemoryLimit memory.limit_in_bytes is a huge number (page aligned
+// math.MaxInt64) if there is no limit.
+const _NoMemoryLimit = 1 << 62
+
+// Memory memory.usage_in_bytes and memory.limit_in_bytes
+func (c *cgroup) Memory() (uint64, uint64, error) {
+	data, err := readFile(path.Join(c.cgroupSet["memory"], "memory.usage_in_bytes"))
+	if err != nil {
+		return 0, 0, err
+	}
+	usage, err := parseUint(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	if data, err = readFile(path.Join(c.cgroupSet["memory"], "memory.limit_in_bytes")); err != nil {
+		return 0, 0, err
+	}
+	limit, err := parseUint(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	if limit >= _NoMemoryLimit {
+		limit = 0
+	}
+	return usage, limit, nil
+}
+
+// cgroupV2 Linux cgroup v2 (unified hierarchy)
+type cgroupV2 struct {
+	dir string
+}
+
+// CPUQuota cpu.max, the format is "$MAX $PERIOD", and $MAX is "max"
+// if there is no limit.
+func (c *cgroupV2) CPUQuota() (int64, uint64, error) {
+	data, err := readFile(path.Join(c.dir, "cpu.max"))
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(data)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, 0, errors.Errorf("os/stat: invalid cpu.max format: %s", data)
+	}
+	if fields[0] == "max" {
+		return -1, 0, nil
+	}
+
+	quota, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "os/stat: parse cpu.max(%s) failed!", data)
+	}
+	// period is 100000 by default if it's omitted.
+	period := uint64(100000)
+	if len(fields) == 2 {
+		if period, err = parseUint(fields[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return quota, period, nil
+}
+
+// CPUUsage usage_usec in cpu.stat
+func (c *cgroupV2) CPUUsage() (uint64, error) {
+	lines, err := readLines(path.Join(c.dir, "cpu.stat"))
+	if err != nil {
+		return 0, errors.Wrapf(err, "os/stat: read file(%s) failed!", path.Join(c.dir, "cpu.stat"))
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			usec, err := parseUint(fields[1])
+			if err != nil {
+				return 0, err
+			}
+			return usec * 1e3, nil
+		}
+	}
+	return 0, errors.Errorf("os/stat: usage_usec not found in cpu.stat")
+}
+
+// CPUAcctUsagePerCPU is not supported by cgroup v2.
+func (c *cgroupV2) CPUAcctUsagePerCPU() ([]uint64, error) {
+	return nil, errors.Errorf("os/stat: per cpu usage is not supported by cgroup v2")
+}
+
+// CPUSetCPUs cpuset.cpus.effective
+func (c *cgroupV2) CPUSetCPUs() ([]uint64, error) {
+	data, err := readFile(path.Join(c.dir, "cpuset.cpus.effective"))
+	if err != nil {
+		return nil, err
+	}
+	return parseCPUSet(data)
+}
+
+// Memory memory.current and memory.max
+func (c *cgroupV2) Memory() (uint64, uint64, error) {
+	data, err := readFile(path.Join(c.dir, "memory.current"))
+	if err != nil {
+		return 0, 0, err
+	}
+	usage, err := parseUint(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	if data, err = readFile(path.Join(c.dir, "memory.max")); err != nil {
+		return 0, 0, err
+	}
+	if data == "max" {
+		return usage, 0, nil
+	}
+	limit, err := parseUint(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	return usage, limit, nil
+}
+
+func parseCPUSet(data string) ([]uint64, error) {
 	cpus, err := ParseUintList(data)
 	if err != nil {
 		return nil, err
@@ -82,10 +248,18 @@ func (c *cgroup) CPUSetCPUs() ([]uint64, error) {
 }
 
 // CurrentcGroup get current process cgroup
-func currentcGroup() (*cgroup, error) {
+func currentcGroup() (cgroupStats, error) {
 	pid := os.Getpid()
 	cgroupFile := fmt.Sprintf("/proc/%d/cgroup", pid)
+	return loadcGroup(cgroupFile, cgroupRootDir)
+}
+
+// loadcGroup parses cgroupFile in format of /proc/$PID/cgroup, and locates
+// the cgroup under root. cgroup v1 is preferred if cpu controllers are
+// mounted in v1 hierarchy, otherwise unified hierarchy (v2) is used.
+func loadcGroup(cgroupFile, root string) (cgroupStats, error) {
 	cgroupSet := make(map[string]string)
+	unified, hasUnified := "", false
 	fp, err := os.Open(cgroupFile)
 	if err != nil {
 		return nil, err
@@ -100,27 +274,51 @@ func currentcGroup() (*cgroup, error) {
 			}
 			return nil, err
 		}
-		col := strings.Split(strings.TrimSpace(line), ":")
+		col := strings.SplitN(strings.TrimSpace(line), ":", 3)
 		if len(col) != 3 {
 			return nil, fmt.Errorf("invalid cgroup format %s", line)
 		}
 		dir := col[2]
+		// unified hierarchy line is "0::$PATH".
+		if col[0] == "0" && col[1] == "" {
+			unified, hasUnified = dir, true
+			continue
+		}
 		// When dir is not equal to /, it must be in docker
 		if dir != "/" {
-			cgroupSet[col[1]] = path.Join(cgroupRootDir, col[1])
+			cgroupSet[col[1]] = path.Join(root, col[1])
 			if strings.Contains(col[1], ",") {
 				for _, k := range strings.Split(col[1], ",") {
-					cgroupSet[k] = path.Join(cgroupRootDir, k)
+					cgroupSet[k] = path.Join(root, k)
 				}
 			}
 		} else {
-			cgroupSet[col[1]] = path.Join(cgroupRootDir, col[1], col[2])
+			cgroupSet[col[1]] = path.Join(root, col[1], col[2])
 			if strings.Contains(col[1], ",") {
 				for _, k := range strings.Split(col[1], ",") {
-					cgroupSet[k] = path.Join(cgroupRootDir, k, col[2])
+					cgroupSet[k] = path.Join(root, k, col[2])
 				}
 			}
 		}
 	}
-	return &cgroup{cgroupSet: cgroupSet}, nil
+
+	_, hasCPU := cgroupSet["cpu"]
+	_, hasCPUAcct := cgroupSet["cpuacct"]
+	if hasCPU && hasCPUAcct {
+		return &cgroup{cgroupSet: cgroupSet}, nil
+	}
+
+	if !hasUnified {
+		return nil, errors.Errorf("no cpu cgroup found in %s", cgroupFile)
+	}
+	if _, err := os.Stat(path.Join(root, "cgroup.controllers")); err != nil {
+		return nil, errors.Wrapf(err, "cgroup v2 is not mounted at %s", root)
+	}
+	// In container with cgroup namespace, the cgroup of process is mounted
+	// at root, otherwise it's the sub-directory of root.
+	dir := path.Join(root, unified)
+	if _, err := os.Stat(path.Join(dir, "cpu.stat")); err != nil {
+		dir = root
+	}
+	return &cgroupV2{dir: dir}, nil
 }
@@ -18,37 +18,43 @@ type cgroupCPU struct {
 	quota     float64
 	cores     uint64
 
+	cg        cgroupStats
 	preSystem uint64
 	preTotal  uint64
 	usage     uint64
 }
 
 func newCGroupCPU() (cpu *cgroupCPU, err error) {
+	var cg cgroupStats
+	if cg, err = currentcGroup(); err != nil {
+		err = errors.Errorf("currentcGroup() failed!err:=%v", err)
+		return
+	}
+	return newCGroupCPUWith(cg)
+}
+
+func newCGroupCPUWith(cg cgroupStats) (cpu *cgroupCPU, err error) {
+	sets, err := cg.CPUSetCPUs()
+	if err != nil {
+		err = errors.Errorf("cpuSets() failed!err:=%v", err)
+		return
+	}
+
 	var cores int
 	cores, err = pscpu.Counts(true)
 	if err != nil || cores == 0 {
 		var cpus []uint64
-		cpus, err = perCPUUsage()
+		cpus, err = cg.CPUAcctUsagePerCPU()
 		if err != nil {
-			err = errors.Errorf("perCPUUsage() failed!err:=%v", err)
-			return
+			// per cpu usage is not supported by cgroup v2
+			cpus = sets
 		}
 		cores = len(cpus)
 	}
 
-	sets, err := cpuSets()
-	if err != nil {
-		err = errors.Errorf("cpuSets() failed!err:=%v", err)
-		return
-	}
 	quota := float64(len(sets))
-	cq, err := cpuQuota()
-	if err == nil && cq != -1 {
-		var period uint64
-		if period, err = cpuPeriod(); err != nil {
-			err = errors.Errorf("cpuPeriod() failed!err:=%v", err)
-			return
-		}
+	cq, period, err := cg.CPUQuota()
+	if err == nil && cq != -1 && period != 0 {
 		limit := float64(cq) / float64(period)
 		if limit < quota {
 			quota = limit
@@ -61,7 +67,7 @@ func newCGroupCPU() (cpu *cgroupCPU, err error) {
 		err = errors.Errorf("systemCPUUsage() failed!err:=%v", err)
 		return
 	}
-	preTotal, err := totalCPUUsage()
+	preTotal, err := cg.CPUUsage()
 	if err != nil {
 		err = errors.Errorf("totalCPUUsage() failed!err:=%v", err)
 		return
@@ -70,6 +76,7 @@ func newCGroupCPU() (cpu *cgroupCPU, err error) {
 		frequency: maxFreq,
 		quota:     quota,
 		cores:     uint64(cores),
+		cg:        cg,
 		preSystem: preSystem,
 		preTotal:  preTotal,
 	}
@@ -81,7 +88,7 @@ func (cpu *cgroupCPU) Usage() (u uint64, err error) {
 		total  uint64
 		system uint64
 	)
-	total, err = totalCPUUsage()
+	total, err = cpu.cg.CPUUsage()
 	if err != nil {
 		return
 	}
@@ -162,46 +169,6 @@ func systemCPUUsage() (usage uint64, err error) {
 	return
 }
 
-func totalCPUUsage() (usage uint64, err error) {
-	var cg *cgroup
-	if cg, err = currentcGroup(); err != nil {
-		return
-	}
-	return cg.CPUAcctUsage()
-}
-
-func perCPUUsage() (usage []uint64, err error) {
-	var cg *cgroup
-	if cg, err = currentcGroup(); err != nil {
-		return
-	}
-	return cg.CPUAcctUsagePerCPU()
-}
-
-func cpuSets() (sets []uint64, err error) {
-	var cg *cgroup
-	if cg, err = currentcGroup(); err != nil {
-		return
-	}
-	return cg.CPUSetCPUs()
-}
-
-func cpuQuota() (quota int64, err error) {
-	var cg *cgroup
-	if cg, err = currentcGroup(); err != nil {
-		return
-	}
-	return cg.CPUCFSQuotaUs()
-}
-
-func cpuPeriod() (peroid uint64, err error) {
-	var cg *cgroup
-	if cg, err = currentcGroup(); err != nil {
-		return
-	}
-	return cg.CPUCFSPeriodUs()
-}
-
 func cpuFreq() uint64 {
 	lines, err := readLines("/proc/cpuinfo")
 	if err != nil {
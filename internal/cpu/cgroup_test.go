@@ -3,9 +3,108 @@
 package cpu
 
 import (
+	"path/filepath"
+	"sort"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
+func loadFixture(t *testing.T, name string) cgroupStats {
+	dir := filepath.Join("testdata", name)
+	cg, err := loadcGroup(filepath.Join(dir, "cgroup"), filepath.Join(dir, "fs"))
+	if err != nil {
+		t.Fatalf("load fixture %s failed: %v", name, err)
+	}
+	return cg
+}
+
 func TestCGroup(t *testing.T) {
-	// TODO
+	cases := []struct {
+		name    string
+		version interface{}
+		quota   int64
+		period  uint64
+		usage   uint64
+		cpus    []uint64
+		memory  [2]uint64
+	}{
+		{
+			name:    "cgroupv1",
+			version: &cgroup{},
+			quota:   150000,
+			period:  100000,
+			usage:   123456789,
+			cpus:    []uint64{0, 1, 2, 3},
+			memory:  [2]uint64{1 << 30, 2 << 30},
+		},
+		{
+			name:    "cgroupv2",
+			version: &cgroupV2{},
+			quota:   200000,
+			period:  100000,
+			usage:   123456000,
+			cpus:    []uint64{0, 1, 2, 3, 4, 5, 6, 7},
+			memory:  [2]uint64{512 << 20, 1 << 30},
+		},
+		{
+			name:    "cgroupv2-nested",
+			version: &cgroupV2{},
+			quota:   -1,
+			period:  0,
+			usage:   42000,
+			cpus:    []uint64{0, 2},
+			memory:  [2]uint64{4096, 0},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cg := loadFixture(t, c.name)
+			assert.IsType(t, c.version, cg)
+
+			quota, period, err := cg.CPUQuota()
+			assert.NoError(t, err)
+			assert.Equal(t, c.quota, quota)
+			assert.Equal(t, c.period, period)
+
+			usage, err := cg.CPUUsage()
+			assert.NoError(t, err)
+			assert.Equal(t, c.usage, usage)
+
+			cpus, err := cg.CPUSetCPUs()
+			assert.NoError(t, err)
+			sort.Slice(cpus, func(i, j int) bool { return cpus[i] < cpus[j] })
+			assert.Equal(t, c.cpus, cpus)
+
+			usage, limit, err := cg.Memory()
+			assert.NoError(t, err)
+			assert.Equal(t, c.memory, [2]uint64{usage, limit})
+		})
+	}
+}
+
+func TestCGroup_perCPU(t *testing.T) {
+	usage, err := loadFixture(t, "cgroupv1").CPUAcctUsagePerCPU()
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{100, 200, 300}, usage)
+
+	_, err = loadFixture(t, "cgroupv2").CPUAcctUsagePerCPU()
+	assert.Error(t, err)
+}
+
+func TestCGroup_notFound(t *testing.T) {
+	// no cpu controllers in v1, and v2 is not mounted.
+	_, err := loadcGroup(filepath.Join("testdata", "cgroupv2-nested", "cgroup"), filepath.Join("testdata", "cgroupv1", "fs"))
+	assert.Error(t, err)
+}
+
+func TestCGroupCPU_quota(t *testing.T) {
+	cpu, err := newCGroupCPUWith(loadFixture(t, "cgroupv2"))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), cpu.Info().Quota)
+
+	cpu, err = newCGroupCPUWith(loadFixture(t, "cgroupv2-nested"))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), cpu.Info().Quota)
 }
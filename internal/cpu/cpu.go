@@ -1,48 +1,57 @@
 package cpu
 
 import (
-	"fmt"
-	"sync/atomic"
-	"time"
-)
+	"sync"
 
-const (
-	interval time.Duration = time.Millisecond * 500
+	"github.com/pkg/errors"
 )
 
 var (
-	stats CPU
+	// defaultStats is the CPU used by ReadStat and GetInfo,
+	// it's created at the first time of them called.
+	defaultStats CPU
+	defaultErr   error
+	defaultOnce  sync.Once
+
+	// mu for defaultStats and usage safety while concurrent visiting.
+	mu    sync.Mutex
 	usage uint64
 )
 
 // CPU is cpu stat usage.
 type CPU interface {
+	// Usage returns cpu usage (permille) since the previous call,
+	// the first call returns usage since CPU created.
 	Usage() (u uint64, e error)
 	Info() Info
 }
 
-func init() {
-	var err error
-	stats, err = newCGroupCPU()
+// New creates a CPU to read cpu usage of current process's cgroup,
+// if cgroup is not available, it switches to host's cpu usage by psutil.
+//
+// Each CPU keeps its own previous usage, so that callers sampling in
+// different interval would not affect each other.
+func New() (CPU, error) {
+	stats, err := newCGroupCPU()
+	if err == nil {
+		return stats, nil
+	}
+
+	// fmt.Printf("cgroup cpu init failed(%v),switch to psutil cpu\n", err)
+	ps, err := newPsutilCPU()
 	if err != nil {
-		// fmt.Printf("cgroup cpu init failed(%v),switch to psutil cpu\n", err)
-		stats, err = newPsutilCPU(interval)
-		if err != nil {
-			panic(fmt.Sprintf("cgroup cpu init failed!err:=%v", err))
-		}
+		return nil, errors.Errorf("cgroup cpu init failed!err:=%v", err)
 	}
 
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			u, err := stats.Usage()
-			if err == nil && u != 0 {
-				atomic.StoreUint64(&usage, u)
-			}
-		}
-	}()
+	return ps, nil
+}
+
+func loadDefault() (CPU, error) {
+	defaultOnce.Do(func() {
+		defaultStats, defaultErr = New()
+	})
+
+	return defaultStats, defaultErr
 }
 
 // Stat cpu stat.
@@ -56,12 +65,31 @@ type Info struct {
 	Quota     float64
 }
 
-// ReadStat read cpu stat.
+// ReadStat read cpu stat since the previous ReadStat called. If there is no
+// cpu usage since then, the previous usage is kept.
+//
+// ReadStat shares one CPU in process, callers those want to sample cpu
+// usage periodically should create their own CPU by New.
 func ReadStat(stat *Stat) {
-	stat.Usage = atomic.LoadUint64(&usage)
+	stats, err := loadDefault()
+	if err != nil {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if u, err := stats.Usage(); err == nil && u != 0 {
+		usage = u
+	}
+	stat.Usage = usage
 }
 
 // GetInfo get cpu info.
 func GetInfo() Info {
+	stats, err := loadDefault()
+	if err != nil {
+		return Info{}
+	}
+
 	return stats.Info()
 }
@@ -0,0 +1,11 @@
+package cpu
+
+// ReadMemory reads memory usage and limit (bytes) of current process's
+// cgroup, limit is 0 if there is no limit.
+func ReadMemory() (usage, limit uint64, err error) {
+	var cg cgroupStats
+	if cg, err = currentcGroup(); err != nil {
+		return
+	}
+	return cg.Memory()
+}
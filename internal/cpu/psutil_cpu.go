@@ -1,19 +1,19 @@
 package cpu
 
 import (
-	"time"
-
+	"github.com/pkg/errors"
 	"github.com/shirou/gopsutil/cpu"
 )
 
 var _ CPU = new(psutilCPU)
 
 type psutilCPU struct {
-	interval time.Duration
+	preBusy  float64
+	preTotal float64
 }
 
-func newPsutilCPU(interval time.Duration) (cpu *psutilCPU, err error) {
-	cpu = &psutilCPU{interval: interval}
+func newPsutilCPU() (cpu *psutilCPU, err error) {
+	cpu = &psutilCPU{}
 	_, err = cpu.Usage()
 	if err != nil {
 		return
@@ -21,12 +21,26 @@ func newPsutilCPU(interval time.Duration) (cpu *psutilCPU, err error) {
 	return
 }
 
+// Usage calculates host's cpu usage from cpu times since previous call.
 func (ps *psutilCPU) Usage() (u uint64, err error) {
-	var percents []float64
-	percents, err = cpu.Percent(ps.interval, false)
-	if err == nil {
-		u = uint64(percents[0] * 10)
+	var times []cpu.TimesStat
+	times, err = cpu.Times(false)
+	if err != nil {
+		return
+	}
+	if len(times) == 0 {
+		err = errors.Errorf("no cpu times")
+		return
+	}
+
+	t := times[0]
+	busy := t.User + t.System + t.Nice + t.Iowait + t.Irq + t.Softirq + t.Steal
+	total := busy + t.Idle
+	if total > ps.preTotal && busy >= ps.preBusy {
+		u = uint64((busy - ps.preBusy) / (total - ps.preTotal) * 1e3)
 	}
+	ps.preBusy = busy
+	ps.preTotal = total
 	return
 }
 
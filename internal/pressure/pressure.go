@@ -0,0 +1,173 @@
+package pressure
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	procPressureDir = "/proc/pressure"
+	procCgroupFile  = "/proc/self/cgroup"
+	cgroupRootDir   = "/sys/fs/cgroup"
+)
+
+// Resource the resource which tasks are stalled on.
+type Resource string
+
+const (
+	// CPU tasks are stalled on cpu.
+	CPU Resource = "cpu"
+	// Memory tasks are stalled on memory.
+	Memory Resource = "memory"
+	// IO tasks are stalled on io.
+	IO Resource = "io"
+)
+
+// Record is a line of pressure stall information, avgs are percentage of
+// stalled time in last 10s, 60s and 300s, total is stalled time in
+// microseconds.
+type Record struct {
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+	Total  uint64
+}
+
+// Stat pressure stall information of a resource.
+//
+// Some means at least some tasks are stalled, and Full means all non-idle
+// tasks are stalled simultaneously. Full is not reported for cpu on
+// system-wide before linux 5.13.
+//
+// https://www.kernel.org/doc/html/latest/accounting/psi.html
+type Stat struct {
+	Some Record
+	Full Record
+}
+
+// Read reads system-wide pressure of resource from /proc/pressure.
+func Read(res Resource) (Stat, error) {
+	return ReadFile(path.Join(procPressureDir, string(res)))
+}
+
+// ReadCgroup reads pressure of resource from cgroup v2 of current process.
+func ReadCgroup(res Resource) (Stat, error) {
+	dir, err := cgroupDir(procCgroupFile, cgroupRootDir)
+	if err != nil {
+		return Stat{}, err
+	}
+	return ReadFile(path.Join(dir, string(res)+".pressure"))
+}
+
+// ReadFile reads pressure from file in format of /proc/pressure/*.
+func ReadFile(filename string) (Stat, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Stat{}, errors.Wrapf(err, "pressure: open file(%s) failed!", filename)
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse parses pressure in format as:
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func Parse(r io.Reader) (Stat, error) {
+	var (
+		stat Stat
+		some bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var rec *Record
+		switch fields[0] {
+		case "some":
+			rec, some = &stat.Some, true
+		case "full":
+			rec = &stat.Full
+		default:
+			return Stat{}, errors.Errorf("pressure: invalid line: %s", scanner.Text())
+		}
+
+		if err := parseRecord(fields[1:], rec); err != nil {
+			return Stat{}, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Stat{}, errors.Wrap(err, "pressure: read failed!")
+	}
+	if !some {
+		return Stat{}, errors.New("pressure: some line not found")
+	}
+
+	return stat, nil
+}
+
+func parseRecord(fields []string, rec *Record) (err error) {
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return errors.Errorf("pressure: invalid field: %s", field)
+		}
+
+		switch kv[0] {
+		case "avg10":
+			rec.Avg10, err = strconv.ParseFloat(kv[1], 64)
+		case "avg60":
+			rec.Avg60, err = strconv.ParseFloat(kv[1], 64)
+		case "avg300":
+			rec.Avg300, err = strconv.ParseFloat(kv[1], 64)
+		case "total":
+			rec.Total, err = strconv.ParseUint(kv[1], 10, 64)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "pressure: parse field(%s) failed!", field)
+		}
+	}
+
+	return nil
+}
+
+// cgroupDir locates the cgroup v2 directory of process under root by
+// the "0::$PATH" line in cgroupFile.
+func cgroupDir(cgroupFile, root string) (string, error) {
+	f, err := os.Open(cgroupFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "pressure: open file(%s) failed!", cgroupFile)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if !strings.HasPrefix(scanner.Text(), "0::") {
+			continue
+		}
+
+		// In container with cgroup namespace, the cgroup of process is
+		// mounted at root, otherwise it's the sub-directory of root.
+		dir := path.Join(root, strings.TrimPrefix(scanner.Text(), "0::"))
+		if _, err := os.Stat(path.Join(dir, "cgroup.controllers")); err == nil {
+			return dir, nil
+		}
+		if _, err := os.Stat(path.Join(root, "cgroup.controllers")); err == nil {
+			return root, nil
+		}
+		return "", errors.Errorf("pressure: cgroup v2 is not mounted at %s", root)
+	}
+
+	return "", errors.Errorf("pressure: cgroup v2 not found in %s", cgroupFile)
+}
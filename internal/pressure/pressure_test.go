@@ -0,0 +1,50 @@
+package pressure
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadFile(t *testing.T) {
+	stat, err := ReadFile(filepath.Join("testdata", "proc", "cpu"))
+	assert.NoError(t, err)
+	assert.Equal(t, Record{Avg10: 2.45, Avg60: 2.22, Avg300: 2.42, Total: 44836339}, stat.Some)
+	assert.Equal(t, Record{}, stat.Full)
+
+	stat, err = ReadFile(filepath.Join("testdata", "proc", "memory"))
+	assert.NoError(t, err)
+	assert.Equal(t, 12.5, stat.Some.Avg10)
+	assert.Equal(t, Record{Avg10: 4.2, Avg60: 1, Avg300: 0.5, Total: 234567}, stat.Full)
+
+	_, err = ReadFile(filepath.Join("testdata", "proc", "invalid"))
+	assert.Error(t, err)
+
+	_, err = ReadFile(filepath.Join("testdata", "proc", "not-exists"))
+	assert.Error(t, err)
+}
+
+func TestParse(t *testing.T) {
+	_, err := Parse(strings.NewReader("some avg10=x avg60=0.00 avg300=0.00 total=0"))
+	assert.Error(t, err)
+
+	_, err = Parse(strings.NewReader("full avg10=0.00 avg60=0.00 avg300=0.00 total=0"))
+	assert.Error(t, err)
+}
+
+func TestCgroupDir(t *testing.T) {
+	root := filepath.Join("testdata", "cgroupv2")
+	dir, err := cgroupDir(filepath.Join(root, "cgroup"), filepath.Join(root, "fs"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "fs", "kubepods", "pod1"), dir)
+
+	stat, err := ReadFile(filepath.Join(dir, string(CPU)+".pressure"))
+	assert.NoError(t, err)
+	assert.Equal(t, 55.0, stat.Some.Avg10)
+	assert.Equal(t, 30.0, stat.Full.Avg10)
+
+	_, err = cgroupDir(filepath.Join("testdata", "proc", "cpu"), root)
+	assert.Error(t, err)
+}
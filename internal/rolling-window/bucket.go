@@ -1,80 +1,170 @@
 package rollingwin
 
 import (
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
-const _InitCap = 128
-
-// Bucket to store all points of
+// Bucket to aggregate all points of one bucket duration, it keeps constant
+// size aggregates (count, sum, min, max and an optional histogram) rather
+// than every point. Points of the current epoch are aggregated by atomic
+// operations with mu's read lock held, and the bucket is reset for a new
+// epoch with mu's write lock held.
 type Bucket struct {
 	// id means the order of the Bucket in RollingWindow.ringBuckets
 	id uint32
-	// mu if lock fo points.
-	mu sync.Mutex
+
+	// mu guards epoch, points of the same epoch are appended concurrently
+	// with read lock held, and the bucket is reset for a new epoch with
+	// write lock held.
+	mu sync.RWMutex
+	// epoch which the bucket belongs to in RollingWindow.
+	epoch int64
 
 	// duration means how long of time-span would be save into
 	// the same one Bucket.
 	duration time.Duration
-	// points recording all point of data.
-	points []int64
-	// count variable for Bucket be called, it's equal to points' length.
+	// count variable for Bucket be called, it's equal to points' count.
 	count uint32
+	// sum of all points.
+	sum int64
+	// min and max of all points, they're math.MaxInt64 and math.MinInt64
+	// if there is no point.
+	min int64
+	max int64
+	// hist recording distribution of points, it's nil if the window is
+	// created without WithHistogram.
+	hist *histogram
 }
 
 func newBucket(dur time.Duration) *Bucket {
-	return &Bucket{
+	b := &Bucket{
 		duration: dur,
-		points:   make([]int64, 0, _InitCap),
-		count:    0,
+		hist:     new(histogram),
 	}
+	b.reset()
+
+	return b
 }
 
 func (b *Bucket) reset() {
 	atomic.StoreUint32(&b.count, 0)
+	atomic.StoreInt64(&b.sum, 0)
+	atomic.StoreInt64(&b.min, math.MaxInt64)
+	atomic.StoreInt64(&b.max, math.MinInt64)
+	if b.hist != nil {
+		b.hist.reset()
+	}
+}
+
+// add appends val into bucket of epoch e, the bucket would be reset if
+// it belongs to an older epoch.
+func (b *Bucket) add(e int64, val int64) {
+	for {
+		b.mu.RLock()
+		// the adder of older epoch is late, val is put into the
+		// newer epoch rather than lost.
+		if b.epoch >= e {
+			b.append(val)
+			b.mu.RUnlock()
+			return
+		}
+		b.mu.RUnlock()
 
-	b.mu.Lock()
-	b.points = make([]int64, 0, _InitCap)
-	b.mu.Unlock()
+		b.mu.Lock()
+		if b.epoch < e {
+			b.reset()
+			b.epoch = e
+		}
+		b.mu.Unlock()
+	}
 }
 
 func (b *Bucket) append(val int64) {
-	atomic.AddUint32(&b.count, 1)
+	atomic.AddInt64(&b.sum, val)
+
+	for min := atomic.LoadInt64(&b.min); val < min; min = atomic.LoadInt64(&b.min) {
+		if atomic.CompareAndSwapInt64(&b.min, min, val) {
+			break
+		}
+	}
+	for max := atomic.LoadInt64(&b.max); val > max; max = atomic.LoadInt64(&b.max) {
+		if atomic.CompareAndSwapInt64(&b.max, max, val) {
+			break
+		}
+	}
+
+	if b.hist != nil {
+		b.hist.add(val)
+	}
 
-	b.mu.Lock()
-	b.points = append(b.points, val)
-	b.mu.Unlock()
+	// count is increased at last, so that there must be aggregates
+	// once count is not 0.
+	atomic.AddUint32(&b.count, 1)
 }
+
 func (b *Bucket) Count() uint32 {
 	return atomic.LoadUint32(&b.count)
 }
 
-// DONE: THINK ABOUT OVERFLOW IF AVG = SUM / COUNT
-func (b *Bucket) Avg() (avg float64) {
-	avg = float64(0)
-	count := 0
+// Sum of all points in Bucket.
+func (b *Bucket) Sum() int64 {
+	return atomic.LoadInt64(&b.sum)
+}
+
+// Min of all points in Bucket, 0 if there is no point.
+func (b *Bucket) Min() int64 {
+	if b.Count() == 0 {
+		return 0
+	}
+	return atomic.LoadInt64(&b.min)
+}
 
-	b.Iterate(func(v int64) {
-		if count == 0 {
-			avg = float64(v)
-		}
+// Max of all points in Bucket, 0 if there is no point.
+func (b *Bucket) Max() int64 {
+	if b.Count() == 0 {
+		return 0
+	}
+	return atomic.LoadInt64(&b.max)
+}
 
-		avg = (avg*float64(count))/float64(count+1) + (float64(v) / float64(count+1))
-		count++
-	})
+// Avg of all points in Bucket, 0 if there is no point.
+func (b *Bucket) Avg() float64 {
+	count := b.Count()
+	if count == 0 {
+		return 0
+	}
 
-	return avg
+	return float64(b.Sum()) / float64(count)
 }
 
-func (b *Bucket) Iterate(f func(v int64)) {
-	b.mu.Lock()
-	dst := make([]int64, b.count)
-	copy(dst, b.points)
-	b.mu.Unlock()
+// Percentile returns the value that p percent of points in Bucket are
+// less than or equal to, p is in [0, 100]. nearest-rank method is used.
+//
+// The value is estimated from histogram with relative error less than
+// 1/8, and clamped by Min and Max. If the window is created without
+// WithHistogram, Avg is returned.
+func (b *Bucket) Percentile(p float64) float64 {
+	if b.hist == nil {
+		return b.Avg()
+	}
 
-	for _, v := range dst {
-		f(v)
+	count := b.Count()
+	if count == 0 {
+		return 0
 	}
+	if p <= 0 {
+		return float64(b.Min())
+	}
+	if p >= 100 {
+		return float64(b.Max())
+	}
+
+	v := b.hist.percentile(p, uint64(count))
+	v = math.Max(v, float64(b.Min()))
+	v = math.Min(v, float64(b.Max()))
+
+	return v
 }
@@ -1,6 +1,7 @@
 package rollingwin
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -17,3 +18,91 @@ func TestBucket_Avg(t *testing.T) {
 
 	assert.Equal(t, float64(1+100)*0.5, b.Avg())
 }
+
+func TestBucket_Sum(t *testing.T) {
+	b := newBucket(_defaultDuration)
+	for i := 1; i <= 100; i++ {
+		b.append(int64(i))
+	}
+
+	assert.Equal(t, int64(5050), b.Sum())
+}
+
+func TestBucket_MinMax(t *testing.T) {
+	b := newBucket(_defaultDuration)
+	assert.Equal(t, int64(0), b.Min())
+	assert.Equal(t, int64(0), b.Max())
+
+	for _, v := range []int64{5, -3, 100, 42} {
+		b.append(v)
+	}
+	assert.Equal(t, int64(-3), b.Min())
+	assert.Equal(t, int64(100), b.Max())
+
+	b.reset()
+	assert.Equal(t, uint32(0), b.Count())
+	assert.Equal(t, int64(0), b.Min())
+	assert.Equal(t, int64(0), b.Sum())
+}
+
+func TestBucket_Percentile(t *testing.T) {
+	b := newBucket(_defaultDuration)
+	assert.Equal(t, float64(0), b.Percentile(99))
+
+	for i := 100; i >= 1; i-- {
+		b.append(int64(i))
+	}
+
+	assert.Equal(t, float64(1), b.Percentile(0))
+	assert.InDelta(t, float64(50), b.Percentile(50), 50.0/8)
+	assert.InDelta(t, float64(90), b.Percentile(90), 90.0/8)
+	assert.InDelta(t, float64(99), b.Percentile(99), 99.0/8)
+	assert.Equal(t, float64(100), b.Percentile(100))
+
+	// without histogram, Avg is used.
+	b.hist = nil
+	assert.Equal(t, b.Avg(), b.Percentile(99))
+}
+
+func Test_histogram(t *testing.T) {
+	for _, v := range []int64{0, 1, 7, 8, 9, 15, 16, 100, 1000, 123456789, math.MaxInt64} {
+		idx := histIndex(v)
+		assert.True(t, idx < _HistBuckets)
+
+		lower, width := histRange(idx)
+		assert.True(t, v >= lower && v-lower < width, "v=%d, lower=%d, width=%d", v, lower, width)
+		assert.True(t, float64(width) <= math.Max(1, float64(v)/_SubBuckets), "v=%d, width=%d", v, width)
+	}
+}
+
+func TestBucket_append_allocs(t *testing.T) {
+	b := newBucket(_defaultDuration)
+	allocs := testing.AllocsPerRun(1000, func() {
+		b.append(42)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func BenchmarkBucket_append(b *testing.B) {
+	bucket := newBucket(_defaultDuration)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bucket.append(int64(i))
+	}
+}
+
+func BenchmarkBucket_append_parallel(b *testing.B) {
+	bucket := newBucket(_defaultDuration)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int64(0)
+		for pb.Next() {
+			bucket.append(i)
+			i++
+		}
+	})
+}
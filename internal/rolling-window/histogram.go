@@ -0,0 +1,99 @@
+package rollingwin
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+const (
+	// _SubBucketBits each power of 2 range is split into 2^_SubBucketBits
+	// sub-buckets, so the relative error of values is 1/2^_SubBucketBits.
+	_SubBucketBits = 3
+	_SubBuckets    = 1 << _SubBucketBits
+	// _HistBuckets count of histogram buckets to cover all non-negative int64,
+	// the highest bit of them is 62.
+	_HistBuckets = (62-_SubBucketBits+1)*_SubBuckets + _SubBuckets
+)
+
+// histogram is a compact log-linear histogram of non-negative values,
+// values less than _SubBuckets are exact, and others are recorded with
+// relative error less than 1/_SubBuckets. It's updated lock-free.
+type histogram struct {
+	counts [_HistBuckets]uint64
+}
+
+// histIndex returns the index of histogram bucket which v belongs to.
+func histIndex(v int64) int {
+	if v < _SubBuckets {
+		if v < 0 {
+			return 0
+		}
+		return int(v)
+	}
+
+	// e >= _SubBucketBits
+	e := bits.Len64(uint64(v)) - 1
+	sub := int(uint64(v)>>uint(e-_SubBucketBits)) & (_SubBuckets - 1)
+
+	return (e-_SubBucketBits+1)*_SubBuckets + sub
+}
+
+// histRange returns the range [lower, lower+width) of histogram bucket idx.
+func histRange(idx int) (lower, width int64) {
+	if idx < _SubBuckets {
+		return int64(idx), 1
+	}
+
+	e := idx/_SubBuckets + _SubBucketBits - 1
+	sub := int64(idx % _SubBuckets)
+	width = int64(1) << uint(e-_SubBucketBits)
+
+	return (_SubBuckets + sub) * width, width
+}
+
+func (h *histogram) add(v int64) {
+	atomic.AddUint64(&h.counts[histIndex(v)], 1)
+}
+
+// merge adds counts of o into h.
+func (h *histogram) merge(o *histogram) {
+	for i := range o.counts {
+		if c := atomic.LoadUint64(&o.counts[i]); c != 0 {
+			atomic.AddUint64(&h.counts[i], c)
+		}
+	}
+}
+
+func (h *histogram) reset() {
+	for i := range h.counts {
+		atomic.StoreUint64(&h.counts[i], 0)
+	}
+}
+
+// percentile returns the estimated value that p percent of values are less
+// than or equal to, total is count of all values. nearest-rank method is
+// used, and the value is the middle of the histogram bucket.
+func (h *histogram) percentile(p float64, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(p / 100 * float64(total)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	cum := uint64(0)
+	for i := range h.counts {
+		cum += atomic.LoadUint64(&h.counts[i])
+		if cum >= rank {
+			lower, width := histRange(i)
+			return float64(lower) + float64(width-1)/2
+		}
+	}
+
+	// points are being added concurrently, total is larger than counts.
+	lower, width := histRange(_HistBuckets - 1)
+	return float64(lower) + float64(width-1)/2
+}
@@ -1,16 +1,20 @@
 package rollingwin
 
 import (
-	"sync"
+	"math"
 	"sync/atomic"
 	"time"
 )
 
-const _BufSize = 0
-
+// RollingWindow is a ring of buckets, each bucket aggregates points of one
+// bucket duration. Add, Iterate and TimeSpan are safe to be called
+// concurrently.
+//
+// Time since the window created is divided into epochs of bucket duration,
+// and the points of epoch e are saved into ringBuckets[e % size]. Each
+// bucket remembers which epoch it belongs to, so that expired buckets are
+// reset lazily by the first Add of new epoch, and skipped by Iterate.
 type RollingWindow struct {
-	// mu for ringBuckets safety while concurrent visiting.
-	mu sync.Mutex
 	// ringBuckets a ring container to storage all.
 	ringBuckets []Bucket
 	// size of buckets.
@@ -18,20 +22,38 @@ type RollingWindow struct {
 	// bucketDuration to indicate how long time-span of each Bucket.
 	bucketDuration time.Duration
 
-	// lastSp to record last offset Bucket index.
-	lastSp uint32
-	// lastAppend the time of last append operation happened.
-	lastAppend time.Time
+	// start the time of window created, epochs are counted from it.
+	start time.Time
+	// lastEpoch the epoch of last append operation happened.
+	lastEpoch int64
+	// histogram indicates whether buckets keep histogram.
+	histogram bool
+	// empty is passed to iterator instead of expired buckets.
+	empty Bucket
+}
+
+// Option to create RollingWindow.
+type Option func(w *RollingWindow)
+
+// WithHistogram makes each Bucket keep a compact histogram, so that
+// Bucket.Percentile could be estimated.
+func WithHistogram() Option {
+	return func(w *RollingWindow) {
+		w.histogram = true
+	}
 }
 
 // NewRollingWindow .
-func NewRollingWindow(size uint32, duration time.Duration) *RollingWindow {
+func NewRollingWindow(size uint32, duration time.Duration, opts ...Option) *RollingWindow {
 	rw := &RollingWindow{
-		ringBuckets:    make([]Bucket, size+_BufSize),
+		ringBuckets:    make([]Bucket, size),
 		size:           size,
 		bucketDuration: duration,
-		lastSp:         0,
-		lastAppend:     time.Now(),
+		start:          time.Now(),
+		lastEpoch:      0,
+	}
+	for _, opt := range opts {
+		opt(rw)
 	}
 	rw.init()
 
@@ -41,65 +63,109 @@ func NewRollingWindow(size uint32, duration time.Duration) *RollingWindow {
 func (w *RollingWindow) init() {
 	for i := uint32(0); i < w.size; i++ {
 		w.ringBuckets[i].id = i
+		w.ringBuckets[i].duration = w.bucketDuration
+		if w.histogram {
+			w.ringBuckets[i].hist = new(histogram)
+		}
+		w.ringBuckets[i].reset()
+		// no epoch is matched at the beginning.
+		w.ringBuckets[i].epoch = math.MinInt64
 	}
+
+	w.empty.duration = w.bucketDuration
+	w.empty.reset()
+}
+
+// epoch returns the epoch of now.
+func (w *RollingWindow) epoch() int64 {
+	return int64(time.Since(w.start) / w.bucketDuration)
 }
 
 // Add variable into window.
 func (w *RollingWindow) Add(val int64) {
-	// sp which Bucket to insert the val.
-	sp := w.TimeSpan()
-	offset := atomic.LoadUint32(&w.lastSp)
-
-	if sp > 0 {
-		// reset expired buckets, sp means how many buckets to clear
-		w.lastAppend = w.lastAppend.Add(w.bucketDuration * time.Duration(sp))
-		// get start position
-		s := w.lastSp + 1
-		if sp > w.size {
-			// if IDLE is more than windows, then clear operation is same to clear all.
-			sp = w.size
-		}
-		// e2 only when IDLE or START is over than size (for ring handling)
-		e1, e2 := s+sp, uint32(0)
-		if e1 > w.size {
-			e2 = e1 - w.size
-			e1 = w.size
-		}
+	e := w.epoch()
+	w.ringBuckets[e%int64(w.size)].add(e, val)
 
-		// reset buckets
-		for i := s; i < e1; i++ {
-			w.ringBuckets[i].reset()
-			offset = i
-		}
-		for i := uint32(0); i < e2; i++ {
-			w.ringBuckets[i].reset()
-			offset = i
+	for last := atomic.LoadInt64(&w.lastEpoch); e > last; last = atomic.LoadInt64(&w.lastEpoch) {
+		if atomic.CompareAndSwapInt64(&w.lastEpoch, last, e) {
+			break
 		}
-		atomic.StoreUint32(&w.lastSp, offset)
 	}
-
-	// calculate which position to append the data.
-	w.ringBuckets[offset].append(val)
 }
 
+// Iterate visits buckets from the oldest to the newest, expired buckets
+// are visited as empty ones. iterator must not keep the bucket after it
+// returns, and must not call Add of the same window.
 func (w *RollingWindow) Iterate(iterator func(b *Bucket)) {
-	w.mu.Lock()
-	sp := w.TimeSpan()
-	if count := w.size; count > 0 {
-		offset := w.lastSp + sp + 1
-		if offset >= w.size {
-			offset = offset - w.size
+	e := w.epoch()
+	for i := e - int64(w.size) + 1; i <= e; i++ {
+		if i < 0 {
+			iterator(&w.empty)
+			continue
 		}
 
-		for i := uint32(0); i < w.size; i++ {
-			pos := (i + offset) % w.size
-			iterator(&(w.ringBuckets[pos]))
+		b := &w.ringBuckets[i%int64(w.size)]
+		b.mu.RLock()
+		if b.epoch == i {
+			iterator(b)
+		} else {
+			iterator(&w.empty)
 		}
+		b.mu.RUnlock()
 	}
-	w.mu.Unlock()
 }
 
 // TimeSpan how many span the is idle since last operation happened.
 func (w *RollingWindow) TimeSpan() uint32 {
-	return uint32(time.Since(w.lastAppend) / w.bucketDuration)
+	return uint32(w.epoch() - atomic.LoadInt64(&w.lastEpoch))
+}
+
+// Percentile returns the value that p percent of points in window are
+// less than or equal to, p is in [0, 100]. Histograms of buckets are
+// merged, so the value is estimated as Bucket.Percentile. If the window is
+// created without WithHistogram, average of points in window is returned.
+func (w *RollingWindow) Percentile(p float64) float64 {
+	var (
+		merged   *histogram
+		count    uint64
+		sum      int64
+		min, max = int64(math.MaxInt64), int64(math.MinInt64)
+	)
+	if w.histogram {
+		merged = new(histogram)
+	}
+
+	w.Iterate(func(b *Bucket) {
+		if b.Count() == 0 {
+			return
+		}
+		count += uint64(b.Count())
+		sum += b.Sum()
+		if b.Min() < min {
+			min = b.Min()
+		}
+		if b.Max() > max {
+			max = b.Max()
+		}
+		if merged != nil && b.hist != nil {
+			merged.merge(b.hist)
+		}
+	})
+
+	switch {
+	case count == 0:
+		return 0
+	case merged == nil:
+		return float64(sum) / float64(count)
+	case p <= 0:
+		return float64(min)
+	case p >= 100:
+		return float64(max)
+	}
+
+	v := merged.percentile(p, count)
+	v = math.Max(v, float64(min))
+	v = math.Min(v, float64(max))
+
+	return v
 }
@@ -3,6 +3,8 @@ package rollingwin
 import (
 	"math"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -21,7 +23,7 @@ func Test_RollingWindow(t *testing.T) {
 
 	assert.Equal(t, 4, int(w.size))
 	assert.Equal(t, 4, len(w.ringBuckets))
-	assert.Equal(t, 0, int(w.lastSp))     // all ops are finished in one seconds
+	assert.Equal(t, 0, int(w.lastEpoch))  // all ops are finished in one seconds
 	assert.Equal(t, 0, int(w.TimeSpan())) // the same reason as above
 
 	total := uint32(0)
@@ -87,12 +89,151 @@ func Test_Iterate(t *testing.T) {
 	}
 }
 
+func TestRollingWindow_expired(t *testing.T) {
+	w := NewRollingWindow(4, 10*time.Millisecond)
+	w.Add(1)
+	w.Add(2)
+
+	// all buckets are expired, and iterated as empty ones.
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, w.TimeSpan() >= 4)
+	count := uint32(0)
+	w.Iterate(func(b *Bucket) {
+		count += b.Count()
+	})
+	assert.Equal(t, uint32(0), count)
+
+	w.Add(3)
+	assert.Equal(t, uint32(0), w.TimeSpan())
+	sum := int64(0)
+	w.Iterate(func(b *Bucket) {
+		sum += b.Sum()
+	})
+	assert.Equal(t, int64(3), sum)
+}
+
+// run with -race to check concurrent Add and Iterate.
+func TestRollingWindow_concurrent(t *testing.T) {
+	const (
+		adders = 8
+		times  = 5000
+	)
+
+	// all points are kept in window.
+	w := NewRollingWindow(10, time.Second, WithHistogram())
+	stop := int32(0)
+	readers := sync.WaitGroup{}
+	readers.Add(2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			defer readers.Done()
+			for atomic.LoadInt32(&stop) == 0 {
+				w.Iterate(func(b *Bucket) {
+					_ = b.Percentile(99)
+					_ = b.Avg()
+				})
+				_ = w.TimeSpan()
+			}
+		}()
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(adders)
+	for i := 0; i < adders; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < times; j++ {
+				w.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	atomic.StoreInt32(&stop, 1)
+	readers.Wait()
+
+	total := int64(0)
+	w.Iterate(func(b *Bucket) {
+		total += b.Sum()
+		assert.Equal(t, int64(b.Count()), b.Sum())
+	})
+	assert.Equal(t, int64(adders*times), total)
+}
+
+// run with -race to check buckets are reset concurrently with Add.
+func TestRollingWindow_concurrent_rolling(t *testing.T) {
+	w := NewRollingWindow(4, time.Millisecond, WithHistogram())
+	deadline := time.Now().Add(100 * time.Millisecond)
+
+	wg := sync.WaitGroup{}
+	wg.Add(9)
+	for i := 0; i < 8; i++ {
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				w.Add(1)
+			}
+		}()
+	}
+	go func() {
+		defer wg.Done()
+		for time.Now().Before(deadline) {
+			w.Iterate(func(b *Bucket) {
+				// aggregates of a bucket are never mixed with expired ones.
+				assert.True(t, b.Sum() <= int64(b.Count())+8)
+			})
+		}
+	}()
+	wg.Wait()
+}
+
+func TestRollingWindow_Percentile(t *testing.T) {
+	w := NewRollingWindow(4, time.Second, WithHistogram())
+	assert.Equal(t, float64(0), w.Percentile(50))
+
+	for i := 1; i <= 1000; i++ {
+		w.Add(int64(i))
+	}
+	assert.Equal(t, float64(1), w.Percentile(0))
+	assert.InDelta(t, float64(500), w.Percentile(50), 500.0/8)
+	assert.InDelta(t, float64(990), w.Percentile(99), 990.0/8)
+	assert.Equal(t, float64(1000), w.Percentile(100))
+
+	w = NewRollingWindow(4, time.Second)
+	w.Add(1)
+	w.Add(3)
+	assert.Equal(t, float64(2), w.Percentile(99))
+}
+
+func TestRollingWindow_Add_allocs(t *testing.T) {
+	w := NewRollingWindow(4, 100*time.Millisecond, WithHistogram())
+	allocs := testing.AllocsPerRun(1000, func() {
+		w.Add(42)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
 func BenchmarkRollingWindow_Add(b *testing.B) {
 	duration := 100 * time.Millisecond
 	w := NewRollingWindow(4, duration)
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		w.Add(int64(i))
 	}
 }
+
+func BenchmarkRollingWindow_Add_parallel(b *testing.B) {
+	duration := 100 * time.Millisecond
+	w := NewRollingWindow(4, duration)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int64(0)
+		for pb.Next() {
+			w.Add(i)
+			i++
+		}
+	})
+}
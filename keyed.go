@@ -0,0 +1,231 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	defaultKeyedConf = &KeyedConfig{
+		MaxKeys:     10000,
+		IdleTimeout: 0,
+	}
+)
+
+// KeyedConfig contains configs of keyed limiter.
+type KeyedConfig struct {
+	// New creates the limiter of key, it's required.
+	New func(key string) Limiter
+	// KeyFunc extracts key from ctx if the request is not allowed with
+	// WithKey. If it's nil, requests without key share the limiter of "".
+	KeyFunc func(ctx context.Context) string
+	// MaxKeys indicates the maximum count of keys kept, the least recently
+	// used key would be evicted. default is 10000.
+	MaxKeys int
+	// IdleTimeout indicates keys not used in IdleTimeout would be evicted,
+	// 0 means never.
+	IdleTimeout time.Duration
+}
+
+func compatibleKeyedConfig(conf *KeyedConfig) *KeyedConfig {
+	if conf == nil || conf.New == nil {
+		panic("ratelimit: KeyedConfig.New is required")
+	}
+
+	if conf.MaxKeys <= 0 {
+		conf.MaxKeys = defaultKeyedConf.MaxKeys
+	}
+	if conf.IdleTimeout < 0 {
+		conf.IdleTimeout = defaultKeyedConf.IdleTimeout
+	}
+
+	return conf
+}
+
+// KeyedLimiter lazily creates a limiter for each key, such as user id or
+// API key, so that each key is limited independently. Keys are evicted by
+// LRU and idle timeout to bound memory, and evicted limiters would be
+// closed if they implement io.Closer.
+type KeyedLimiter struct {
+	conf *KeyedConfig
+
+	// mu for lru and entries safety while concurrent visiting.
+	mu sync.Mutex
+	// lru the front is the most recently used entry.
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+// keyedEntry is the limiter and stats of a key.
+type keyedEntry struct {
+	key     string
+	limiter Limiter
+
+	// lastAccess is guarded by KeyedLimiter.mu.
+	lastAccess time.Time
+	allowed    uint64
+	rejected   uint64
+}
+
+// KeyStat contains the stats' snapshot of a key.
+type KeyStat struct {
+	Key        string
+	Allowed    uint64    // count of allowed requests
+	Rejected   uint64    // count of rejected requests
+	LastAccess time.Time // the time of the latest request
+}
+
+// NewKeyed create a keyed limiter.
+func NewKeyed(conf *KeyedConfig) Limiter {
+	conf = compatibleKeyedConfig(conf)
+
+	return &KeyedLimiter{
+		conf:    conf,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// entry returns the entry of key, it's created if not exists.
+func (k *KeyedLimiter) entry(key string) *keyedEntry {
+	now := time.Now()
+	var evicted []*keyedEntry
+
+	k.mu.Lock()
+	elem, ok := k.entries[key]
+	if ok {
+		k.lru.MoveToFront(elem)
+	} else {
+		elem = k.lru.PushFront(&keyedEntry{key: key, limiter: k.conf.New(key)})
+		k.entries[key] = elem
+	}
+	e := elem.Value.(*keyedEntry)
+	e.lastAccess = now
+	evicted = k.evict(now)
+	k.mu.Unlock()
+
+	for _, old := range evicted {
+		if c, ok := old.limiter.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+
+	return e
+}
+
+// evict removes entries over MaxKeys or idle, it must be called with
+// k.mu held.
+func (k *KeyedLimiter) evict(now time.Time) (evicted []*keyedEntry) {
+	for elem := k.lru.Back(); elem != nil; elem = k.lru.Back() {
+		e := elem.Value.(*keyedEntry)
+		idle := k.conf.IdleTimeout > 0 && now.Sub(e.lastAccess) > k.conf.IdleTimeout
+		if k.lru.Len() <= k.conf.MaxKeys && !idle {
+			break
+		}
+
+		k.lru.Remove(elem)
+		delete(k.entries, e.key)
+		evicted = append(evicted, e)
+	}
+
+	return evicted
+}
+
+// Allow checks inbound traffic by the limiter of key, the key is from
+// WithKey or KeyedConfig.KeyFunc.
+func (k *KeyedLimiter) Allow(ctx context.Context, opts ...AllowOption) (func(info DoneInfo), error) {
+	allowOpts := DefaultAllowOpts()
+	for _, opt := range opts {
+		opt.Apply(&allowOpts)
+	}
+
+	key := allowOpts.Key
+	if key == "" && k.conf.KeyFunc != nil {
+		key = k.conf.KeyFunc(ctx)
+	}
+
+	e := k.entry(key)
+	done, err := e.limiter.Allow(ctx, opts...)
+	if err != nil {
+		atomic.AddUint64(&e.rejected, 1)
+		return nil, err
+	}
+	atomic.AddUint64(&e.allowed, 1)
+
+	return done, nil
+}
+
+// Limiter returns the limiter of key, false if key is not kept.
+func (k *KeyedLimiter) Limiter(key string) (Limiter, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	elem, ok := k.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	return elem.Value.(*keyedEntry).limiter, true
+}
+
+// Stat tasks a snapshot of key, false if key is not kept.
+func (k *KeyedLimiter) Stat(key string) (KeyStat, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	elem, ok := k.entries[key]
+	if !ok {
+		return KeyStat{}, false
+	}
+
+	return elem.Value.(*keyedEntry).stat(), true
+}
+
+// Stats tasks snapshots of all kept keys, from the most recently used.
+func (k *KeyedLimiter) Stats() []KeyStat {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	stats := make([]KeyStat, 0, k.lru.Len())
+	for elem := k.lru.Front(); elem != nil; elem = elem.Next() {
+		stats = append(stats, elem.Value.(*keyedEntry).stat())
+	}
+
+	return stats
+}
+
+func (e *keyedEntry) stat() KeyStat {
+	return KeyStat{
+		Key:        e.key,
+		Allowed:    atomic.LoadUint64(&e.allowed),
+		Rejected:   atomic.LoadUint64(&e.rejected),
+		LastAccess: e.lastAccess,
+	}
+}
+
+// Close closes limiters of all keys those implement io.Closer.
+func (k *KeyedLimiter) Close() error {
+	k.mu.Lock()
+	entries := make([]*keyedEntry, 0, k.lru.Len())
+	for elem := k.lru.Front(); elem != nil; elem = elem.Next() {
+		entries = append(entries, elem.Value.(*keyedEntry))
+	}
+	k.lru.Init()
+	k.entries = make(map[string]*list.Element)
+	k.mu.Unlock()
+
+	var err error
+	for _, e := range entries {
+		if c, ok := e.limiter.(io.Closer); ok {
+			if cerr := c.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockLimiter allows at most quota requests, and gives the quota back if
+// the request is ignored. It records DoneInfo of requests and whether it's
+// closed.
+type mockLimiter struct {
+	quota  int
+	infos  []DoneInfo
+	closed bool
+}
+
+func (l *mockLimiter) Allow(ctx context.Context, opts ...AllowOption) (func(info DoneInfo), error) {
+	if l.quota <= 0 {
+		return nil, ErrLimitExceed
+	}
+	l.quota--
+	return func(info DoneInfo) {
+		if info.Op == Ignore {
+			l.quota++
+		}
+		l.infos = append(l.infos, info)
+	}, nil
+}
+
+func (l *mockLimiter) Close() error {
+	l.closed = true
+	return nil
+}
+
+type ctxKey struct{}
+
+func TestKeyedLimiter_Allow(t *testing.T) {
+	l := NewKeyed(&KeyedConfig{
+		New: func(key string) Limiter { return &mockLimiter{quota: 2} },
+		KeyFunc: func(ctx context.Context) string {
+			key, _ := ctx.Value(ctxKey{}).(string)
+			return key
+		},
+	}).(*KeyedLimiter)
+
+	for i := 0; i < 2; i++ {
+		_, err := l.Allow(context.Background(), WithKey("a"))
+		assert.NoError(t, err)
+	}
+	_, err := l.Allow(context.Background(), WithKey("a"))
+	assert.True(t, errors.Is(err, ErrLimitExceed))
+
+	// key b is limited independently, from KeyFunc.
+	ctx := context.WithValue(context.Background(), ctxKey{}, "b")
+	_, err = l.Allow(ctx)
+	assert.NoError(t, err)
+	// WithKey takes precedence over KeyFunc.
+	_, err = l.Allow(ctx, WithKey("a"))
+	assert.True(t, errors.Is(err, ErrLimitExceed))
+
+	stat, ok := l.Stat("a")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), stat.Allowed)
+	assert.Equal(t, uint64(2), stat.Rejected)
+	stat, _ = l.Stat("b")
+	assert.Equal(t, uint64(1), stat.Allowed)
+
+	stats := l.Stats()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "a", stats[0].Key)
+}
+
+func TestKeyedLimiter_evict(t *testing.T) {
+	limiters := make(map[string]*mockLimiter)
+	l := NewKeyed(&KeyedConfig{
+		New: func(key string) Limiter {
+			limiters[key] = &mockLimiter{quota: 10}
+			return limiters[key]
+		},
+		MaxKeys:     2,
+		IdleTimeout: 20 * time.Millisecond,
+	}).(*KeyedLimiter)
+
+	for _, key := range []string{"a", "b", "a", "c"} {
+		_, err := l.Allow(context.Background(), WithKey(key))
+		assert.NoError(t, err)
+	}
+
+	// b is the least recently used.
+	_, ok := l.Limiter("b")
+	assert.False(t, ok)
+	assert.True(t, limiters["b"].closed)
+	_, ok = l.Limiter("a")
+	assert.True(t, ok)
+
+	// a and c are idle.
+	time.Sleep(30 * time.Millisecond)
+	_, err := l.Allow(context.Background(), WithKey("d"))
+	assert.NoError(t, err)
+	assert.Len(t, l.Stats(), 1)
+	assert.True(t, limiters["a"].closed)
+	assert.True(t, limiters["c"].closed)
+
+	assert.NoError(t, l.Close())
+	assert.True(t, limiters["d"].closed)
+	assert.Len(t, l.Stats(), 0)
+}
@@ -0,0 +1,65 @@
+package httplimit
+
+import (
+	"net/http"
+
+	limit "github.com/yeqown/ratelimit"
+)
+
+var (
+	defaultConf = &Config{
+		StatusCode: http.StatusTooManyRequests,
+	}
+)
+
+// Config contains configs of the http middleware.
+type Config struct {
+	// Limiter limits inbound requests, it's required.
+	Limiter limit.Limiter
+	// KeyFunc extracts the key of request, such as user id or API key,
+	// it's passed to Limiter by limit.WithKey.
+	KeyFunc func(req *http.Request) string
+	// PriorityFunc extracts the priority of request, it's passed to Limiter
+	// by limit.WithPriority.
+	PriorityFunc func(req *http.Request) limit.Priority
+	// Exempt reports whether request should not be limited, such as health
+	// checks. ExemptPaths could be used to exempt routes.
+	Exempt func(req *http.Request) bool
+	// StatusCode is responded when request is limited, 429 or 503 is
+	// suggested. default is 429.
+	StatusCode int
+	// ErrorHandler responds the limited request, the Retry-After header has
+	// been set if possible. default responds StatusCode and the error.
+	ErrorHandler func(w http.ResponseWriter, req *http.Request, err error)
+}
+
+func compatibleConfig(conf *Config) *Config {
+	if conf == nil || conf.Limiter == nil {
+		panic("httplimit: Config.Limiter is required")
+	}
+
+	if conf.StatusCode <= 0 {
+		conf.StatusCode = defaultConf.StatusCode
+	}
+	if conf.ErrorHandler == nil {
+		statusCode := conf.StatusCode
+		conf.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+			http.Error(w, err.Error(), statusCode)
+		}
+	}
+
+	return conf
+}
+
+// ExemptPaths returns an exempt function which exempts requests of paths.
+func ExemptPaths(paths ...string) func(req *http.Request) bool {
+	set := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		set[path] = struct{}{}
+	}
+
+	return func(req *http.Request) bool {
+		_, ok := set[req.URL.Path]
+		return ok
+	}
+}
@@ -0,0 +1,156 @@
+package httplimit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	limit "github.com/yeqown/ratelimit"
+)
+
+// StatusError indicates the request is responded with an error status.
+type StatusError int
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("http status %d %s", int(e), http.StatusText(int(e)))
+}
+
+// New creates a middleware which limits inbound requests by conf.Limiter.
+//
+// The outcome of handler is reported to the limiter by DoneInfo:
+// 429 and 503 mean the handler is overloaded, they are reported as Drop;
+// other 5xx and panics are reported as Success with error; requests canceled
+// by client and hijacked connections are reported as Ignore, since they
+// tell nothing about the capacity of the server.
+func New(conf *Config) func(next http.Handler) http.Handler {
+	conf = compatibleConfig(conf)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if conf.Exempt != nil && conf.Exempt(req) {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			opts := make([]limit.AllowOption, 0, 2)
+			if conf.KeyFunc != nil {
+				opts = append(opts, limit.WithKey(conf.KeyFunc(req)))
+			}
+			if conf.PriorityFunc != nil {
+				opts = append(opts, limit.WithPriority(conf.PriorityFunc(req)))
+			}
+
+			done, err := conf.Limiter.Allow(req.Context(), opts...)
+			if err != nil {
+				var le *limit.LimitError
+				if errors.As(err, &le) && le.RetryAfter > 0 {
+					w.Header().Set("Retry-After", strconv.FormatInt(le.RetryAfterSeconds(), 10))
+				}
+				conf.ErrorHandler(w, req, err)
+				return
+			}
+
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				if r := recover(); r != nil {
+					done(panicInfo(r))
+					panic(r)
+				}
+				done(doneInfo(req, sw.status))
+			}()
+
+			// keep http.Hijacker visible to handlers, such as websocket
+			// upgrades, only if the underlying writer supports it.
+			var rw http.ResponseWriter = sw
+			if _, ok := w.(http.Hijacker); ok {
+				rw = &hijackWriter{sw}
+			}
+
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// doneInfo maps the response status of req to DoneInfo.
+func doneInfo(req *http.Request, status int) limit.DoneInfo {
+	if err := req.Context().Err(); err != nil {
+		return limit.DoneInfo{Err: err, Op: limit.Ignore}
+	}
+
+	switch {
+	case status == http.StatusSwitchingProtocols:
+		// the connection is upgraded or hijacked, its RT is the lifetime of
+		// connection rather than the cost of request.
+		return limit.DoneInfo{Op: limit.Ignore}
+	case status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable:
+		return limit.DoneInfo{Err: StatusError(status), Op: limit.Drop}
+	case status >= http.StatusInternalServerError:
+		return limit.DoneInfo{Err: StatusError(status), Op: limit.Success}
+	}
+
+	return limit.DoneInfo{Op: limit.Success}
+}
+
+// panicInfo maps the recovered value of handler to DoneInfo.
+func panicInfo(r interface{}) limit.DoneInfo {
+	if r == http.ErrAbortHandler {
+		return limit.DoneInfo{Err: http.ErrAbortHandler, Op: limit.Ignore}
+	}
+
+	return limit.DoneInfo{Err: fmt.Errorf("panic: %v", r), Op: limit.Success}
+}
+
+// statusWriter records the status code written by handler.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying writer does.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// hijackWriter is a statusWriter which implements http.Hijacker, it's used
+// only if the underlying writer does.
+type hijackWriter struct {
+	*statusWriter
+}
+
+// Hijack implements http.Hijacker, the hijacked request is recorded as
+// 101 Switching Protocols.
+func (w *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, brw, err
+}
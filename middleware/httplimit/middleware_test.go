@@ -0,0 +1,153 @@
+package httplimit
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yeqown/ratelimit"
+)
+
+// mockLimiter rejects requests with err if it's not nil, and records
+// options and DoneInfo of requests.
+type mockLimiter struct {
+	err   error
+	opts  []ratelimit.AllowOption
+	infos []ratelimit.DoneInfo
+}
+
+func (l *mockLimiter) Allow(ctx context.Context, opts ...ratelimit.AllowOption) (func(info ratelimit.DoneInfo), error) {
+	l.opts = opts
+	if l.err != nil {
+		return nil, l.err
+	}
+	return func(info ratelimit.DoneInfo) { l.infos = append(l.infos, info) }, nil
+}
+
+func statusHandler(status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(status)
+	})
+}
+
+func TestNew_limited(t *testing.T) {
+	l := &mockLimiter{err: &ratelimit.LimitError{
+		Limiter:    "bbr",
+		Reason:     ratelimit.ReasonCPUOverload,
+		RetryAfter: 1500 * time.Millisecond,
+	}}
+	h := New(&Config{
+		Limiter:    l,
+		StatusCode: http.StatusServiceUnavailable,
+		Exempt:     ExemptPaths("/healthz"),
+	})(statusHandler(http.StatusOK))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestNew_options(t *testing.T) {
+	l := &mockLimiter{}
+	h := New(&Config{
+		Limiter:      l,
+		KeyFunc:      func(req *http.Request) string { return req.Header.Get("X-API-Key") },
+		PriorityFunc: func(req *http.Request) ratelimit.Priority { return ratelimit.PriorityHigh },
+	})(statusHandler(http.StatusOK))
+
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set("X-API-Key", "key")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	o := ratelimit.DefaultAllowOpts()
+	for _, opt := range l.opts {
+		opt.Apply(&o)
+	}
+	assert.Equal(t, "key", o.Key)
+	assert.Equal(t, ratelimit.PriorityHigh, o.Priority)
+}
+
+func TestNew_outcome(t *testing.T) {
+	cases := []struct {
+		status int
+		op     ratelimit.Op
+		err    error
+	}{
+		{status: http.StatusOK, op: ratelimit.Success},
+		{status: http.StatusNotFound, op: ratelimit.Success},
+		{status: http.StatusInternalServerError, op: ratelimit.Success, err: StatusError(500)},
+		{status: http.StatusTooManyRequests, op: ratelimit.Drop, err: StatusError(429)},
+		{status: http.StatusServiceUnavailable, op: ratelimit.Drop, err: StatusError(503)},
+	}
+
+	for _, c := range cases {
+		l := &mockLimiter{}
+		New(&Config{Limiter: l})(statusHandler(c.status)).
+			ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, []ratelimit.DoneInfo{{Err: c.err, Op: c.op}}, l.infos, "status=%d", c.status)
+	}
+
+	// canceled by client.
+	l := &mockLimiter{}
+	ctx, cancel := context.WithCancel(context.Background())
+	New(&Config{Limiter: l})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cancel()
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	assert.Equal(t, []ratelimit.DoneInfo{{Err: context.Canceled, Op: ratelimit.Ignore}}, l.infos)
+
+	// panic is reported and re-panicked.
+	l = &mockLimiter{}
+	h := New(&Config{Limiter: l})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		panic("boom")
+	}))
+	assert.PanicsWithValue(t, "boom", func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+	assert.Len(t, l.infos, 1)
+	assert.Equal(t, ratelimit.Success, l.infos[0].Op)
+	assert.EqualError(t, l.infos[0].Err, "panic: boom")
+}
+
+// hijackRecorder is a ResponseRecorder which implements http.Hijacker.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, _ := net.Pipe()
+	return conn, bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)), nil
+}
+
+func TestNew_hijack(t *testing.T) {
+	l := &mockLimiter{}
+	hijackable := false
+	h := New(&Config{Limiter: l})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var hj http.Hijacker
+		hj, hijackable = w.(http.Hijacker)
+		if !hijackable {
+			return
+		}
+		conn, _, err := hj.Hijack()
+		assert.NoError(t, err)
+		_ = conn.Close()
+	}))
+
+	// the underlying writer could not be hijacked.
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.False(t, hijackable)
+
+	h.ServeHTTP(hijackRecorder{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, hijackable)
+	assert.Equal(t, []ratelimit.DoneInfo{{Op: ratelimit.Success}, {Op: ratelimit.Ignore}}, l.infos)
+}
@@ -0,0 +1,119 @@
+package httplimit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	limit "github.com/yeqown/ratelimit"
+)
+
+// TransportConfig contains configs of the limited http.RoundTripper.
+type TransportConfig struct {
+	// Base sends requests, default is http.DefaultTransport.
+	Base http.RoundTripper
+	// Limiter limits outbound requests, it's required.
+	Limiter limit.Limiter
+	// KeyFunc extracts the key of request, such as the host of downstream,
+	// it's passed to Limiter by limit.WithKey.
+	KeyFunc func(req *http.Request) string
+	// PriorityFunc extracts the priority of request, it's passed to Limiter
+	// by limit.WithPriority.
+	PriorityFunc func(req *http.Request) limit.Priority
+	// Wait indicates waiting for the permit until the context of request is
+	// done rather than failing at once, it works only if Limiter implements
+	// limit.Waiter.
+	Wait bool
+}
+
+func compatibleTransportConfig(conf *TransportConfig) *TransportConfig {
+	if conf == nil || conf.Limiter == nil {
+		panic("httplimit: TransportConfig.Limiter is required")
+	}
+
+	if conf.Base == nil {
+		conf.Base = http.DefaultTransport
+	}
+
+	return conf
+}
+
+// transport limits outbound requests before sending them by base.
+type transport struct {
+	conf   *TransportConfig
+	waiter limit.Waiter
+}
+
+// NewTransport creates a http.RoundTripper which limits outbound requests by
+// conf.Limiter, the error of Limiter is returned if request is limited.
+//
+// The outcome of request is reported to the limiter by DoneInfo once the
+// response header is received: 429, 503 and timeouts mean the downstream is
+// overloaded, they are reported as Drop; other 5xx and errors are reported
+// as Success with error; requests canceled by caller are reported as Ignore.
+func NewTransport(conf *TransportConfig) http.RoundTripper {
+	conf = compatibleTransportConfig(conf)
+
+	t := &transport{conf: conf}
+	if conf.Wait {
+		t.waiter, _ = conf.Limiter.(limit.Waiter)
+	}
+
+	return t
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, err := t.allow(req)
+	if err != nil {
+		// RoundTrip must always close the body, including on errors.
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := t.conf.Base.RoundTrip(req)
+	if err != nil {
+		done(errorInfo(req.Context(), err))
+		return nil, err
+	}
+
+	done(doneInfo(req, resp.StatusCode))
+	return resp, nil
+}
+
+// allow asks the permit of req from limiter.
+func (t *transport) allow(req *http.Request) (func(info limit.DoneInfo), error) {
+	if t.waiter != nil {
+		// waiters consume permits while waiting, there is nothing to report.
+		if err := t.waiter.Wait(req.Context(), 1); err != nil {
+			return nil, err
+		}
+		return func(limit.DoneInfo) {}, nil
+	}
+
+	opts := make([]limit.AllowOption, 0, 2)
+	if t.conf.KeyFunc != nil {
+		opts = append(opts, limit.WithKey(t.conf.KeyFunc(req)))
+	}
+	if t.conf.PriorityFunc != nil {
+		opts = append(opts, limit.WithPriority(t.conf.PriorityFunc(req)))
+	}
+
+	return t.conf.Limiter.Allow(req.Context(), opts...)
+}
+
+// errorInfo maps the error of round trip to DoneInfo.
+func errorInfo(ctx context.Context, err error) limit.DoneInfo {
+	if errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) {
+		return limit.DoneInfo{Err: err, Op: limit.Ignore}
+	}
+
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return limit.DoneInfo{Err: err, Op: limit.Drop}
+	}
+
+	return limit.DoneInfo{Err: err, Op: limit.Success}
+}
@@ -0,0 +1,137 @@
+package httplimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yeqown/ratelimit"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// timeoutError is a net.Error which is timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// mockWaiter records permits waited.
+type mockWaiter struct {
+	mockLimiter
+	waited int64
+}
+
+func (w *mockWaiter) Wait(ctx context.Context, n int64) error {
+	w.waited += n
+	return w.err
+}
+
+func (w *mockWaiter) Reserve(n int64) ratelimit.Reservation { return nil }
+
+func TestTransport(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	l := &mockLimiter{}
+	client := &http.Client{Transport: NewTransport(&TransportConfig{Limiter: l})}
+	for _, status = range []int{http.StatusOK, http.StatusServiceUnavailable, http.StatusInternalServerError} {
+		resp, err := client.Get(srv.URL)
+		assert.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	assert.Equal(t, []ratelimit.DoneInfo{
+		{Op: ratelimit.Success},
+		{Err: StatusError(503), Op: ratelimit.Drop},
+		{Err: StatusError(500), Op: ratelimit.Success},
+	}, l.infos)
+
+	// limited locally.
+	l.err = ratelimit.ErrLimitExceed
+	_, err := client.Get(srv.URL)
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+}
+
+func TestTransport_error(t *testing.T) {
+	l := &mockLimiter{}
+	var rtErr error
+	rt := NewTransport(&TransportConfig{
+		Base:    roundTripFunc(func(req *http.Request) (*http.Response, error) { return nil, rtErr }),
+		Limiter: l,
+	})
+
+	cases := []struct {
+		err error
+		op  ratelimit.Op
+	}{
+		{err: timeoutError{}, op: ratelimit.Drop},
+		{err: context.DeadlineExceeded, op: ratelimit.Drop},
+		{err: context.Canceled, op: ratelimit.Ignore},
+		{err: errors.New("connection refused"), op: ratelimit.Success},
+	}
+	for _, c := range cases {
+		rtErr = c.err
+		_, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, c.err, err)
+		assert.Equal(t, ratelimit.DoneInfo{Err: c.err, Op: c.op}, l.infos[len(l.infos)-1])
+	}
+}
+
+func TestTransport_wait(t *testing.T) {
+	w := &mockWaiter{}
+	rt := NewTransport(&TransportConfig{
+		Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		}),
+		Limiter: w,
+		Wait:    true,
+	})
+
+	_, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), w.waited)
+	assert.Len(t, w.infos, 0)
+
+	w.err = context.DeadlineExceeded
+	_, err = rt.RoundTrip(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+// closeRecorder records whether it's closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestTransport_closeBody(t *testing.T) {
+	rt := NewTransport(&TransportConfig{
+		Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			t.Fatal("limited request should not be sent")
+			return nil, nil
+		}),
+		Limiter: &mockLimiter{err: ratelimit.ErrLimitExceed},
+	})
+
+	body := &closeRecorder{Reader: strings.NewReader("body")}
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	_, err := rt.RoundTrip(req)
+	assert.True(t, errors.Is(err, ratelimit.ErrLimitExceed))
+	assert.True(t, body.closed)
+}
@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+type allowOptionFunc func(*allowOptions)
+
+func (f allowOptionFunc) Apply(o *allowOptions) {
+	f(o)
+}
+
+// WithCost indicates the request consumes n permits, n must be positive.
+func WithCost(n int64) AllowOption {
+	return allowOptionFunc(func(o *allowOptions) {
+		if n > 0 {
+			o.Cost = n
+		}
+	})
+}
+
+// WithPriority indicates the priority of the request.
+func WithPriority(p Priority) AllowOption {
+	return allowOptionFunc(func(o *allowOptions) {
+		o.Priority = p
+	})
+}
+
+// WithKey indicates who is calling, such as user id or API key.
+func WithKey(key string) AllowOption {
+	return allowOptionFunc(func(o *allowOptions) {
+		o.Key = key
+	})
+}
+
+// WithDeadline indicates the request should be allowed before deadline,
+// otherwise it makes no sense to the caller.
+func WithDeadline(deadline time.Time) AllowOption {
+	return allowOptionFunc(func(o *allowOptions) {
+		o.Deadline = deadline
+	})
+}
+
+// Expired reports whether the deadline of request has passed.
+func (o allowOptions) Expired() bool {
+	return !o.Deadline.IsZero() && !time.Now().Before(o.Deadline)
+}
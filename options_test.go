@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowOptions(t *testing.T) {
+	o := DefaultAllowOpts()
+	assert.Equal(t, int64(1), o.Cost)
+	assert.Equal(t, PriorityNormal, o.Priority)
+	assert.False(t, o.Expired())
+
+	deadline := time.Now().Add(-time.Second)
+	for _, opt := range []AllowOption{
+		WithCost(3),
+		WithCost(0),
+		WithPriority(PriorityCritical),
+		WithKey("user-1"),
+		WithDeadline(deadline),
+	} {
+		opt.Apply(&o)
+	}
+
+	assert.Equal(t, int64(3), o.Cost)
+	assert.Equal(t, PriorityCritical, o.Priority)
+	assert.Equal(t, "user-1", o.Key)
+	assert.Equal(t, deadline, o.Deadline)
+	assert.True(t, o.Expired())
+}
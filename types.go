@@ -2,6 +2,7 @@ package ratelimit
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -23,7 +24,32 @@ const (
 	Drop
 )
 
-type allowOptions struct{}
+// Priority indicates how important a request is, limiters would shed
+// requests in lower priority first.
+type Priority int
+
+const (
+	// PriorityLow request priority: low, such as background jobs.
+	PriorityLow Priority = iota
+	// PriorityNormal request priority: normal, the default one.
+	PriorityNormal
+	// PriorityHigh request priority: high.
+	PriorityHigh
+	// PriorityCritical request priority: critical, such as health checks.
+	PriorityCritical
+)
+
+// allowOptions contains attributes of the request to be checked.
+type allowOptions struct {
+	// Cost how many permits the request consumes.
+	Cost int64
+	// Priority of the request.
+	Priority Priority
+	// Key identifies who is calling, such as user id or API key.
+	Key string
+	// Deadline the request should be allowed before, zero means no deadline.
+	Deadline time.Time
+}
 
 // AllowOptions allow options.
 type AllowOption interface {
@@ -38,10 +64,35 @@ type DoneInfo struct {
 
 // DefaultAllowOpts returns the default allow options.
 func DefaultAllowOpts() allowOptions {
-	return allowOptions{}
+	return allowOptions{
+		Cost:     1,
+		Priority: PriorityNormal,
+	}
 }
 
 // Limiter limit interface.
 type Limiter interface {
 	Allow(ctx context.Context, opts ...AllowOption) (func(info DoneInfo), error)
 }
+
+// Reservation holds permits reserved from a limiter, the permits could be
+// used after Delay.
+type Reservation interface {
+	// OK reports whether the permits could be reserved, if not,
+	// Delay and Cancel make no sense.
+	OK() bool
+	// Delay returns how long the caller should wait before using the permits.
+	Delay() time.Duration
+	// Cancel gives back the permits to the limiter if they are not used yet.
+	Cancel()
+}
+
+// Waiter is implemented by rate-based limiters, it blocks the caller until
+// permitted instead of raising ErrLimitExceed immediately.
+// Each permit means what a single request consumes in Limiter.Allow.
+type Waiter interface {
+	// Wait blocks until n permits are available or ctx is done.
+	Wait(ctx context.Context, n int64) error
+	// Reserve reserves n permits and tells the caller how long to wait.
+	Reserve(n int64) Reservation
+}
@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// WaitReservation blocks until the reservation r could be used.
+// If r is not OK it raises ErrLimitExceed error, and if ctx's deadline is
+// earlier than the permits are available, it raises *LimitError with
+// ReasonDeadline, the caller could fill LimitError.Limiter with its own
+// name. If ctx is done while waiting,
+// ctx.Err() would be returned. Permits would be given back to limiter in
+// both cases.
+func WaitReservation(ctx context.Context, r Reservation) error {
+	if !r.OK() {
+		return ErrLimitExceed
+	}
+
+	delay := r.Delay()
+	if delay <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		r.Cancel()
+		return &LimitError{
+			Reason: ReasonDeadline,
+			Observed: map[string]float64{
+				"delay_ms": float64(delay) / float64(time.Millisecond),
+			},
+			RetryAfter: delay,
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
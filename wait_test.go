@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockReservation struct {
+	ok       bool
+	delay    time.Duration
+	canceled bool
+}
+
+func (r *mockReservation) OK() bool             { return r.ok }
+func (r *mockReservation) Delay() time.Duration { return r.delay }
+func (r *mockReservation) Cancel()              { r.canceled = true }
+
+func TestWaitReservation(t *testing.T) {
+	r := &mockReservation{ok: false}
+	assert.Equal(t, ErrLimitExceed, WaitReservation(context.Background(), r))
+
+	r = &mockReservation{ok: true, delay: 10 * time.Millisecond}
+	assert.NoError(t, WaitReservation(context.Background(), r))
+	assert.False(t, r.canceled)
+
+	// deadline is earlier than delay.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	r = &mockReservation{ok: true, delay: time.Second}
+	err := WaitReservation(ctx, r)
+	assert.True(t, errors.Is(err, ErrLimitExceed))
+	assert.Equal(t, ReasonDeadline, err.(*LimitError).Reason)
+	assert.True(t, r.canceled)
+
+	// canceled while waiting.
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	time.AfterFunc(5*time.Millisecond, cancel2)
+	r = &mockReservation{ok: true, delay: time.Second}
+	assert.Equal(t, context.Canceled, WaitReservation(ctx2, r))
+	assert.True(t, r.canceled)
+}
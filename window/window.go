@@ -0,0 +1,146 @@
+// Package window provides rolling metrics over a sliding time window,
+// such as error rate or latency of recent requests.
+//
+// The window is a ring of buckets, each bucket aggregates values of one
+// bucket duration, and buckets older than the window are dropped.
+package window
+
+import (
+	"math"
+	"time"
+
+	rw "github.com/yeqown/ratelimit/internal/rolling-window"
+)
+
+// RollingCounter counts values in a sliding window, it's safe to be used
+// concurrently.
+type RollingCounter struct {
+	w *rw.RollingWindow
+
+	// span the time-span of whole window.
+	span time.Duration
+	// start the time of counter created.
+	start time.Time
+}
+
+// NewRollingCounter create a RollingCounter holds size buckets, and each
+// bucket covers bucketDuration, so the window is size * bucketDuration.
+func NewRollingCounter(size uint32, bucketDuration time.Duration) *RollingCounter {
+	return newRollingCounter(size, bucketDuration)
+}
+
+func newRollingCounter(size uint32, bucketDuration time.Duration, opts ...rw.Option) *RollingCounter {
+	return &RollingCounter{
+		w:     rw.NewRollingWindow(size, bucketDuration, opts...),
+		span:  time.Duration(size) * bucketDuration,
+		start: time.Now(),
+	}
+}
+
+// Add value into window.
+func (c *RollingCounter) Add(v int64) {
+	c.w.Add(v)
+}
+
+// Count of values added in window.
+func (c *RollingCounter) Count() int64 {
+	count := int64(0)
+	c.w.Iterate(func(b *rw.Bucket) {
+		count += int64(b.Count())
+	})
+
+	return count
+}
+
+// Sum of values in window.
+func (c *RollingCounter) Sum() int64 {
+	sum := int64(0)
+	c.w.Iterate(func(b *rw.Bucket) {
+		sum += b.Sum()
+	})
+
+	return sum
+}
+
+// Avg of values in window, 0 if there is no value.
+func (c *RollingCounter) Avg() float64 {
+	var (
+		sum   int64
+		count int64
+	)
+	c.w.Iterate(func(b *rw.Bucket) {
+		sum += b.Sum()
+		count += int64(b.Count())
+	})
+	if count == 0 {
+		return 0
+	}
+
+	return float64(sum) / float64(count)
+}
+
+// Min of values in window, 0 if there is no value.
+func (c *RollingCounter) Min() int64 {
+	min, ok := int64(math.MaxInt64), false
+	c.w.Iterate(func(b *rw.Bucket) {
+		if b.Count() != 0 && b.Min() < min {
+			min, ok = b.Min(), true
+		}
+	})
+	if !ok {
+		return 0
+	}
+
+	return min
+}
+
+// Max of values in window, 0 if there is no value.
+func (c *RollingCounter) Max() int64 {
+	max, ok := int64(math.MinInt64), false
+	c.w.Iterate(func(b *rw.Bucket) {
+		if b.Count() != 0 && b.Max() > max {
+			max, ok = b.Max(), true
+		}
+	})
+	if !ok {
+		return 0
+	}
+
+	return max
+}
+
+// Rate returns Sum per second. If the counter is younger than the window,
+// only the time since it's created is counted.
+func (c *RollingCounter) Rate() float64 {
+	span := c.span
+	if elapsed := time.Since(c.start); elapsed < span {
+		span = elapsed
+	}
+	if span <= 0 {
+		return 0
+	}
+
+	return float64(c.Sum()) / span.Seconds()
+}
+
+// RollingHistogram is a RollingCounter which could also estimate
+// percentiles of values in window. Values should be non-negative, such
+// as latency.
+type RollingHistogram struct {
+	*RollingCounter
+}
+
+// NewRollingHistogram create a RollingHistogram holds size buckets, and
+// each bucket covers bucketDuration, so the window is size * bucketDuration.
+func NewRollingHistogram(size uint32, bucketDuration time.Duration) *RollingHistogram {
+	return &RollingHistogram{
+		RollingCounter: newRollingCounter(size, bucketDuration, rw.WithHistogram()),
+	}
+}
+
+// Percentile returns the value that p percent of values in window are less
+// than or equal to, p is in [0, 100]. It's estimated with relative error
+// less than 1/8.
+func (h *RollingHistogram) Percentile(p float64) float64 {
+	return h.w.Percentile(p)
+}
@@ -0,0 +1,67 @@
+package window
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollingCounter(t *testing.T) {
+	c := NewRollingCounter(10, 100*time.Millisecond)
+	assert.Equal(t, int64(0), c.Count())
+	assert.Equal(t, float64(0), c.Avg())
+	assert.Equal(t, int64(0), c.Min())
+	assert.Equal(t, int64(0), c.Max())
+
+	for _, v := range []int64{3, 1, 4, 1, 5, 9, 2, 6} {
+		c.Add(v)
+	}
+
+	assert.Equal(t, int64(8), c.Count())
+	assert.Equal(t, int64(31), c.Sum())
+	assert.Equal(t, 31.0/8, c.Avg())
+	assert.Equal(t, int64(1), c.Min())
+	assert.Equal(t, int64(9), c.Max())
+}
+
+func TestRollingCounter_Rate(t *testing.T) {
+	c := NewRollingCounter(5, 20*time.Millisecond)
+	for i := 0; i < 10; i++ {
+		c.Add(1)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// 10 in about 50ms
+	rate := c.Rate()
+	assert.True(t, rate >= 100 && rate <= 200, "rate=%f", rate)
+
+	// values are out of window.
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int64(0), c.Sum())
+	assert.Equal(t, float64(0), c.Rate())
+}
+
+func TestRollingHistogram(t *testing.T) {
+	h := NewRollingHistogram(10, 100*time.Millisecond)
+
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer wg.Done()
+			for v := int64(1); v <= 100; v++ {
+				h.Add(v)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(400), h.Count())
+	assert.Equal(t, float64(1), h.Percentile(0))
+	assert.InDelta(t, float64(50), h.Percentile(50), 50.0/8)
+	assert.InDelta(t, float64(99), h.Percentile(99), 99.0/8)
+	assert.Equal(t, float64(100), h.Percentile(100))
+	assert.Equal(t, 50.5, h.Avg())
+}